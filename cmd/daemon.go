@@ -0,0 +1,97 @@
+/*
+Copyright © 2023 DUEX COAST duexcoast@gmail.com
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/duexcoast/tidy-up/pkg/daemon"
+	"github.com/duexcoast/tidy-up/pkg/logger"
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+)
+
+type daemonCmdOptions struct {
+	envFiles []string
+}
+
+func init() {
+	opts := &daemonCmdOptions{}
+	cmd := newDaemonCommand(opts)
+	rootCmd.AddCommand(cmd)
+
+	cmd.PersistentFlags().StringSliceVar(&opts.envFiles, "env-file", []string{}, "Env files to parse environment variables (looks for .env by default).")
+}
+
+func newDaemonCommand(opts *daemonCmdOptions) *cobra.Command {
+	return &cobra.Command{
+
+		Use:   "daemon",
+		Short: "This command will sort the directories listed as jobs in the config on their schedules.",
+		Long: `Runs every job listed in the config on its cron schedule, reporting the
+result of every run, and watches the directories of jobs with watch set. The
+daemon is controlled over a Unix socket, see tidy status and tidy sort
+--via-daemon. Send SIGHUP to reload the config, runs until interrupted.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			l := logger.Get()
+			err := godotenv.Load(opts.envFiles...)
+			if err != nil {
+				l.Error().Err(err).Msg("error loading env files.")
+			}
+			// the exit code tells a service manager that the daemon failed.
+			if err := runDaemon(opts); err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+}
+
+// runDaemon runs the daemon until it is interrupted, returning the error it
+// failed with, if any.
+func runDaemon(opts *daemonCmdOptions) error {
+	l := logger.Get()
+
+	d, err := daemon.New(loadConfig)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		return err
+	}
+	if err := d.Start(); err != nil {
+		fmt.Printf("error: %s\n", err)
+		return err
+	}
+	defer d.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- d.Serve(ctx, rootOpts.socket) }()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case err := <-served:
+			fmt.Printf("error: %s\n", err)
+			return err
+		case sig := <-signals:
+			if sig != syscall.SIGHUP {
+				l.Info().Str("Signal", sig.String()).Msg("Stopping daemon.")
+				return nil
+			}
+			if err := d.Reload(); err != nil {
+				l.Error().Err(err).Msg("Could not reload config, keeping the current jobs.")
+				continue
+			}
+			l.Info().Msg("Reloaded config.")
+		}
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright © 2023 DUEX COAST duexcoast@gmail.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/duexcoast/tidy-up/pkg/logger"
+	"github.com/duexcoast/tidy-up/pkg/tidy"
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+)
+
+type explainCmdOptions struct {
+	verbose  bool
+	envFiles []string
+	dest     string
+	template string
+}
+
+func init() {
+	opts := &explainCmdOptions{}
+	cmd := newExplainCommand(opts)
+	rootCmd.AddCommand(cmd)
+
+	cmd.Flags().StringVarP(&opts.dest, "dest", "d", "", "Root directory that would be sorted into (defaults to the directory of the file)")
+	cmd.Flags().StringVar(&opts.template, "template", "", "Template for the path of sorted files, e.g. \"{category}/{year}/{name}\"")
+	cmd.PersistentFlags().BoolVarP(&opts.verbose, "verbose", "v", false, "verbose output")
+
+	cmd.PersistentFlags().StringSliceVar(&opts.envFiles, "env-file", []string{}, "Env files to parse environment variables (looks for .env by default).")
+}
+
+func newExplainCommand(opts *explainCmdOptions) *cobra.Command {
+	return &cobra.Command{
+
+		Use:   "explain <file>",
+		Short: "This command will explain where sort would move a file, and why.",
+		Long: `Prints the chain of decisions tidy makes about a file: the rules and
+extension lookup consulted, the folder chosen, the rename rules and path
+template applied, and what happens if the destination is already taken.
+Nothing is moved.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			l := logger.Get()
+			err := godotenv.Load(opts.envFiles...)
+			if err != nil {
+				l.Error().Err(err).Msg("error loading env files.")
+			}
+			// the exit code tells scripts that the file could not be explained.
+			if err := runExplain(opts, args); err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+}
+
+// runExplain explains where the file would be sorted, returning the error it
+// could not be explained with, if any.
+func runExplain(opts *explainCmdOptions, args []string) error {
+	Tidy, err := newTidy(opts.verbose)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		return err
+	}
+	defer Tidy.Close()
+
+	if opts.dest != "" {
+		if err := Tidy.ChangeDestDir(opts.dest); err != nil {
+			fmt.Printf("error: %s\n", err)
+			return err
+		}
+	}
+
+	if opts.template != "" {
+		pt, err := tidy.ParsePathTemplate(opts.template)
+		if err != nil {
+			fmt.Printf("error: %s\n", err)
+			return err
+		}
+		Tidy.Template = pt
+	}
+
+	// explain only reads the directory, so protected ones can be looked at.
+	Tidy.Force = true
+
+	// the file is explained as part of the directory it is in.
+	if err := Tidy.ChangeSortDir(filepath.Dir(args[0])); err != nil {
+		fmt.Printf("error: %s\n", err)
+		return err
+	}
+	explanation, err := Tidy.Explain(filepath.Base(args[0]))
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		return err
+	}
+	fmt.Println(explanation)
+	return nil
+}
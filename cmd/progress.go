@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/duexcoast/tidy-up/pkg/logger"
+	"github.com/duexcoast/tidy-up/pkg/tidy"
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	// barRedraw is how often the progress bar is redrawn at most.
+	barRedraw = 100 * time.Millisecond
+
+	// progressLogInterval is how often progress is logged when stderr is not a
+	// terminal.
+	progressLogInterval = 5 * time.Second
+
+	barWidth = 30
+)
+
+// progressReporter shows the progress of a sort, as a bar on stderr when it is a
+// terminal, and as a log line every progressLogInterval otherwise.
+type progressReporter struct {
+	tty  bool
+	last time.Time
+
+	// drawn reports whether the bar is on screen, and has to be ended with a
+	// newline.
+	drawn bool
+}
+
+func newProgressReporter() *progressReporter {
+	fd := os.Stderr.Fd()
+	return &progressReporter{tty: isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)}
+}
+
+// report shows p, unless it was shown too recently. The last report of a sort is
+// always shown.
+func (pr *progressReporter) report(p tidy.Progress) {
+	interval := progressLogInterval
+	if pr.tty {
+		interval = barRedraw
+	}
+	if p.Done < p.Total && time.Since(pr.last) < interval {
+		return
+	}
+	pr.last = time.Now()
+
+	if !pr.tty {
+		if p.Done == 0 || p.Done == p.Total {
+			return
+		}
+		l := logger.Get()
+		l.Info().Int("Done", p.Done).Int("Total", p.Total).Str("Moved", formatBytes(p.Bytes)).Dur("ETA", p.ETA.Round(time.Second)).Msg("Sorting.")
+		return
+	}
+
+	filled := barWidth
+	percent := 100
+	if p.Total > 0 {
+		filled = barWidth * p.Done / p.Total
+		percent = 100 * p.Done / p.Total
+	}
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled)
+	eta := "--"
+	if p.Done > 0 {
+		eta = p.ETA.Round(time.Second).String()
+	}
+	// \r\033[K moves back to the start of the line and clears it.
+	fmt.Fprintf(os.Stderr, "\r\033[K[%s] %d/%d %3d%% %s ETA %s %s", bar, p.Done, p.Total, percent, formatBytes(p.Bytes), eta, truncateName(p.Current, 30))
+	pr.drawn = true
+}
+
+// finish ends the line of the progress bar, so that what is printed next starts
+// on a line of its own.
+func (pr *progressReporter) finish() {
+	if pr.drawn {
+		fmt.Fprintln(os.Stderr)
+		pr.drawn = false
+	}
+}
+
+// truncateName shortens name to at most n runes, marking the cut with "…".
+func truncateName(name string, n int) string {
+	r := []rune(name)
+	if len(r) <= n {
+		return name
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -6,13 +6,20 @@ package cmd
 import (
 	"os"
 
+	"github.com/duexcoast/tidy-up/pkg/daemon"
+	"github.com/duexcoast/tidy-up/pkg/tidy"
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 )
 
 type rootCmdOptions struct {
 	toggle bool
+	config string
+	socket string
 }
 
+var rootOpts = &rootCmdOptions{}
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "tidy",
@@ -39,9 +46,51 @@ func Execute() {
 // 	rootCmd.AddCommand(cleanCmd)
 // }
 
+// loadConfig reads the config file passed with --config, or the one in the
+// default location. A missing config file in the default location is not an
+// error, an empty config is returned instead.
+func loadConfig() (*tidy.Config, error) {
+	path := rootOpts.config
+	if path == "" {
+		defaultPath, err := tidy.DefaultConfigPath()
+		if err != nil {
+			return &tidy.Config{}, nil
+		}
+		if _, err := os.Stat(defaultPath); os.IsNotExist(err) {
+			return &tidy.Config{}, nil
+		}
+		path = defaultPath
+	}
+	return tidy.LoadConfig(afero.NewOsFs(), path)
+}
+
+// newTidy initializes a Tidy for the filesystem of the OS, with the config
+// file applied. The Tidy has to be closed once done, which flushes the events
+// of its webhooks.
+func newTidy(verbose bool) (*tidy.Tidy, error) {
+	flags := &tidy.TidyFlags{Verbose: verbose}
+	t, err := tidy.NewTidy(tidy.NewFiletypeSorter(), flags, afero.NewOsFs())
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Apply(t); err != nil {
+		// the webhooks configured before the error are running already.
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
 func init() {
 
-	opts := &rootCmdOptions{}
+	opts := rootOpts
 	rootCmd.Flags().BoolVarP(&opts.toggle, "toggle", "t", false, "Help message for toggle")
+	rootCmd.PersistentFlags().StringVarP(&opts.config, "config", "c", "", "Path to the config file (defaults to tidy/config.yaml in the user config directory).")
+	rootCmd.PersistentFlags().StringVar(&opts.socket, "socket", daemon.DefaultSocketPath(), "Path to the Unix socket of the daemon's control API.")
 	// rootCmd.PersistentFlags().BoolVarP(&opts.verbose, "verbose", "v", false, "verbose output")
 }
@@ -0,0 +1,146 @@
+/*
+Copyright © 2023 DUEX COAST duexcoast@gmail.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/duexcoast/tidy-up/pkg/service"
+	"github.com/duexcoast/tidy-up/pkg/tidy"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+type serviceCmdOptions struct {
+	unitDir  string
+	schedule string
+}
+
+func init() {
+	opts := &serviceCmdOptions{}
+	cmd := newServiceCommand(opts)
+	rootCmd.AddCommand(cmd)
+
+	cmd.PersistentFlags().StringVar(&opts.unitDir, "unit-dir", "", "Directory to write the unit files to (defaults to systemd/user in the user config directory)")
+}
+
+func newServiceCommand(opts *serviceCmdOptions) *cobra.Command {
+	cmd := &cobra.Command{
+
+		Use:   "service",
+		Short: "This command manages the systemd user service which runs tidy in the background.",
+		Long: `Installs, inspects and removes a systemd user service for tidy. By default the
+service runs tidy daemon with the current config. With --schedule, a timer sorts
+a single directory on the schedule instead.`,
+	}
+
+	install := &cobra.Command{
+		Use:   "install [path]",
+		Short: "Write the unit files, then enable and start them.",
+		Args:  cobra.RangeArgs(0, 1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runServiceInstall(opts, args)
+		},
+	}
+	install.Flags().StringVar(&opts.schedule, "schedule", "", "Sort the directory on this systemd calendar expression, e.g. hourly, instead of running the daemon")
+	cmd.AddCommand(install)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Show the status of the installed units.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			s, err := newService(opts)
+			if err != nil {
+				fmt.Printf("error: %s\n", err)
+				return
+			}
+			status, err := s.Status()
+			if err != nil {
+				fmt.Printf("error: %s\n", err)
+				return
+			}
+			fmt.Print(status)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "uninstall",
+		Short: "Stop and disable the units, and remove their files.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			s, err := newService(opts)
+			if err != nil {
+				fmt.Printf("error: %s\n", err)
+				return
+			}
+			if err := s.Uninstall(); err != nil {
+				fmt.Printf("error: %s\n", err)
+			}
+		},
+	})
+
+	return cmd
+}
+
+// newService returns the Service for the running binary, writing into the unit
+// directory passed with --unit-dir.
+func newService(opts *serviceCmdOptions) (*service.Service, error) {
+	unitDir := opts.unitDir
+	if unitDir == "" {
+		var err error
+		if unitDir, err = service.DefaultUnitDir(); err != nil {
+			return nil, err
+		}
+	}
+	return service.New(afero.NewOsFs(), unitDir)
+}
+
+func runServiceInstall(opts *serviceCmdOptions, args []string) {
+	s, err := newService(opts)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		return
+	}
+
+	// the service does not run in the current directory, so every path handed to
+	// it has to be absolute.
+	config := rootOpts.config
+	if config == "" {
+		if path, err := tidy.DefaultConfigPath(); err == nil {
+			if _, err := os.Stat(path); err == nil {
+				config = path
+			}
+		}
+	}
+	if config != "" {
+		if s.Config, err = filepath.Abs(config); err != nil {
+			fmt.Printf("error: %s\n", err)
+			return
+		}
+	}
+
+	if opts.schedule != "" {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		s.Schedule = opts.schedule
+		if s.Dir, err = filepath.Abs(dir); err != nil {
+			fmt.Printf("error: %s\n", err)
+			return
+		}
+	} else if len(args) == 1 {
+		fmt.Printf("error: a path can only be given together with --schedule\n")
+		return
+	}
+
+	if err := s.Install(); err != nil {
+		fmt.Printf("error: %s\n", err)
+		return
+	}
+	fmt.Printf("Installed %s in %s\n", s.Name, s.UnitDir)
+}
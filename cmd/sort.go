@@ -4,19 +4,41 @@ Copyright © 2023 DUEX COAST duexcoast@gmail.com
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
+	"github.com/duexcoast/tidy-up/pkg/daemon"
 	"github.com/duexcoast/tidy-up/pkg/logger"
 	"github.com/duexcoast/tidy-up/pkg/tidy"
 	"github.com/joho/godotenv"
-	"github.com/spf13/afero"
+	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 )
 
 type sortCmdOptions struct {
-	sortType string
-	verbose  bool
-	envFiles []string
+	sortType   string
+	verbose    bool
+	envFiles   []string
+	dest       string
+	template   string
+	rename     []string
+	onConflict string
+	onClash    string
+	failFast   bool
+	full       bool
+	force      bool
+	wait       time.Duration
+	jobs       int
+	viaDaemon  bool
+	output     string
 }
 
 // sortCmd represents the clean command
@@ -29,6 +51,18 @@ func init() {
 	cmd.Flags().StringVarP(&opts.sortType, "type", "t", "filetypeSorter", "The sort type to be used")
 	cmd.PersistentFlags().BoolVarP(&opts.verbose, "verbose", "v", false, "verbose output")
 
+	cmd.Flags().StringVarP(&opts.dest, "dest", "d", "", "Root directory to sort into (defaults to the sorted directory itself)")
+	cmd.Flags().StringVar(&opts.template, "template", "", "Template for the path of sorted files, e.g. \"{category}/{year}/{name}\"")
+	cmd.Flags().StringSliceVar(&opts.rename, "rename", nil, "Rename rules applied to sorted files (collapse-whitespace, strip-copy-suffix, lowercase-ext, slugify, date-prefix)")
+	cmd.Flags().StringVar(&opts.onConflict, "on-conflict", "", "What to do when the destination already exists: skip, rename or overwrite")
+	cmd.Flags().StringVar(&opts.onClash, "on-clash", "", "What to do when a file is in the way of a sorting folder: sort it, rename it, or rename-folder to use another folder")
+	cmd.Flags().IntVarP(&opts.jobs, "jobs", "j", 1, "Number of files to move at the same time, which speeds up sorting on network filesystems")
+	cmd.Flags().BoolVar(&opts.full, "full", false, "Look at every entry again, including the ones the last sort left in place which have not changed since")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "Sort the directory even if it is protected, such as the home directory or a git repository")
+	cmd.Flags().DurationVar(&opts.wait, "wait", 0, "How long to wait for another sort of the directory to finish, negative to wait until it does")
+	cmd.Flags().BoolVar(&opts.failFast, "fail-fast", false, "Stop at the first file that can not be moved, instead of moving every other file first")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "text", "Output format: text for a table, json for the result as JSON, or jsonl for one JSON event per line followed by a summary")
+	cmd.Flags().BoolVar(&opts.viaDaemon, "via-daemon", false, "Ask the running daemon to sort the directory, using its config")
 	cmd.PersistentFlags().StringSliceVar(&opts.envFiles, "env-file", []string{}, "Env files to parse environment variables (looks for .env by default).")
 }
 
@@ -41,32 +75,232 @@ func newSortCommand(opts *sortCmdOptions) *cobra.Command {
 		Long:    ``,
 		Args:    cobra.RangeArgs(0, 1),
 		Run: func(cmd *cobra.Command, args []string) {
+			// scripts read the events from stdout, which the console log would
+			// interleave with.
+			if opts.output == "jsonl" || opts.output == "json" {
+				zerolog.SetGlobalLevel(zerolog.Disabled)
+			}
 			l := logger.Get()
 			err := godotenv.Load(opts.envFiles...)
 			if err != nil {
 				l.Error().Err(err).Msg("error loading env files.")
 			}
-			runSort(opts, args)
+			// every failure has been reported by now, the exit code tells scripts
+			// that something went wrong.
+			if err := runSort(opts, args); err != nil {
+				os.Exit(1)
+			}
 		},
 	}
 }
 
-func runSort(opts *sortCmdOptions, args []string) {
-	flags := &tidy.TidyFlags{Verbose: opts.verbose}
-	Tidy, err := tidy.NewTidy(tidy.NewFiletypeSorter(), flags, afero.NewOsFs())
-	if err != nil {
+// runSort sorts the directory and prints what it did, returning the error the
+// sort failed with, if any. An interrupt stops the sort after the moves which
+// already started, and what was left undone is printed.
+func runSort(opts *sortCmdOptions, args []string) error {
+	if opts.viaDaemon {
+		return runSortViaDaemon(opts, args)
+	}
+
+	var jsonl *tidy.JSONLinesSink
+	switch opts.output {
+	case "text", "json":
+	case "jsonl":
+		jsonl = tidy.NewJSONLinesSink(os.Stdout)
+	default:
+		err := fmt.Errorf("unknown output %q, expected text, json or jsonl", opts.output)
 		fmt.Printf("error: %s\n", err)
+		return err
+	}
+
+	var sinks []tidy.EventSink
+	if jsonl != nil {
+		sinks = append(sinks, jsonl)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	progress := newProgressReporter()
+	if progress.tty && !opts.verbose && zerolog.GlobalLevel() < zerolog.WarnLevel {
+		// the log line of every move would break up the progress bar.
+		zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	}
+	result, err := sortDir(ctx, opts, args, progress.report, sinks...)
+	progress.finish()
+
+	switch opts.output {
+	case "jsonl":
+		jsonl.WriteSummary(err)
+	case "json":
+		if result == nil {
+			result = &tidy.Result{}
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+	default:
+		if result != nil {
+			printResult(result)
+		}
+		if err != nil && (result == nil || !result.Cancelled) {
+			printError(err)
+		}
+	}
+	return err
+}
+
+// printResult prints what a sort did as a table, with a row for every category
+// files were moved into.
+func printResult(result *tidy.Result) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "CATEGORY\tFILES\tSIZE\t")
+	for _, c := range result.Categories {
+		fmt.Fprintf(w, "%s\t%d\t%s\t\n", c.Name, c.Files, formatBytes(c.Bytes))
+	}
+	fmt.Fprintf(w, "Total\t%d\t%s\t\n", result.Moved, formatBytes(result.Bytes))
+	w.Flush()
+
+	fmt.Printf("\nSkipped %d, conflicts %d, errors %d, in %s.\n", result.Skipped, result.Conflicts, result.Errors, result.Elapsed.Round(time.Millisecond))
+	if result.Clashes > 0 {
+		fmt.Printf("Found %d files in the way of the sorting folders, see the log for how they were handled.\n", result.Clashes)
+	}
+	if result.Unchanged > 0 {
+		fmt.Printf("Left %d unchanged entries alone, use --full to look at them again.\n", result.Unchanged)
+	}
+	if result.Cancelled {
+		fmt.Printf("Cancelled, %d entries were left where they are. Sort again to finish, or undo to bring back the files which were moved.\n", result.Remaining)
+	}
+}
+
+// printError prints err, along with how to sort anyway if err refuses to sort a
+// protected directory.
+func printError(err error) {
+	fmt.Printf("error: %s\n", err)
+	var pe *tidy.ProtectedPathError
+	if errors.As(err, &pe) {
+		fmt.Println("Use --force to sort it anyway.")
+	}
+}
+
+// formatBytes formats a number of bytes with a binary unit, such as "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
 	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// sortDir sorts the directory given in args, or the current directory, with the
+// config and the flags applied, until ctx is cancelled. The progress of the sort
+// is reported to onProgress, and every event is sent to sinks.
+func sortDir(ctx context.Context, opts *sortCmdOptions, args []string, onProgress func(tidy.Progress), sinks ...tidy.EventSink) (*tidy.Result, error) {
+	Tidy, err := newTidy(opts.verbose)
+	if err != nil {
+		return nil, err
+	}
+	defer Tidy.Close()
+	Tidy.Sinks = append(Tidy.Sinks, sinks...)
+	Tidy.OnProgress = onProgress
+
+	if opts.dest != "" {
+		if err := Tidy.ChangeDestDir(opts.dest); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.template != "" {
+		pt, err := tidy.ParsePathTemplate(opts.template)
+		if err != nil {
+			return nil, err
+		}
+		Tidy.Template = pt
+	}
+
+	if opts.rename != nil {
+		rules, err := tidy.ParseRenameRules(opts.rename)
+		if err != nil {
+			return nil, err
+		}
+		Tidy.RenameRules = rules
+	}
+
+	if opts.onConflict != "" {
+		policy, err := tidy.ParseConflictPolicy(opts.onConflict)
+		if err != nil {
+			return nil, err
+		}
+		Tidy.OnConflict = policy
+	}
+
+	if opts.onClash != "" {
+		policy, err := tidy.ParseClashPolicy(opts.onClash)
+		if err != nil {
+			return nil, err
+		}
+		Tidy.OnClash = policy
+	}
+
+	if opts.failFast {
+		Tidy.FailFast = true
+	}
+	if opts.full {
+		Tidy.Full = true
+	}
+	if opts.force {
+		Tidy.Force = true
+	}
+	if opts.wait != 0 {
+		Tidy.LockWait = opts.wait
+	}
+	Tidy.Workers = opts.jobs
 
 	// arg is path of directory to be sorted
 	if len(args) == 1 {
-		err := Tidy.ChangeSortDir(args[0])
-		if err != nil {
-			fmt.Printf("error: %s\n", err)
+		if err := Tidy.ChangeSortDir(args[0]); err != nil {
+			return nil, err
 		}
 	}
-	err = Tidy.Sort()
+	return Tidy.SortContext(ctx)
+}
+
+// runSortViaDaemon asks the daemon to sort the directory, and waits for the
+// result.
+func runSortViaDaemon(opts *sortCmdOptions, args []string) error {
+	if opts.dest != "" || opts.template != "" || opts.rename != nil || opts.onConflict != "" || opts.onClash != "" || opts.failFast || opts.full || opts.force || opts.wait != 0 || opts.jobs != 1 {
+		err := errors.New("--via-daemon sorts using the config of the daemon, and can not be combined with flags configuring the sort")
+		fmt.Printf("error: %s\n", err)
+		return err
+	}
+
+	dir := "."
+	if len(args) == 1 {
+		dir = args[0]
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		return err
+	}
+
+	run, err := daemon.NewClient(rootOpts.socket).SortDir(dir)
 	if err != nil {
 		fmt.Printf("error: %s\n", err)
+		return err
+	}
+	if run.Error != "" {
+		fmt.Printf("error: %s\n", run.Error)
+		return errors.New(run.Error)
 	}
+	fmt.Printf("Sorted %s in %s\n", run.Dir, run.Finished.Sub(run.Started).Round(time.Millisecond))
+	return nil
 }
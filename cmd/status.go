@@ -0,0 +1,244 @@
+/*
+Copyright © 2023 DUEX COAST duexcoast@gmail.com
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/duexcoast/tidy-up/pkg/daemon"
+	"github.com/duexcoast/tidy-up/pkg/tidy"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+type statusCmdOptions struct {
+	daemon   bool
+	runs     int
+	dest     string
+	template string
+	output   string
+}
+
+func init() {
+	opts := &statusCmdOptions{}
+	cmd := newStatusCommand(opts)
+	rootCmd.AddCommand(cmd)
+
+	cmd.Flags().BoolVar(&opts.daemon, "daemon", false, "Show the jobs and recent runs of the running daemon instead")
+	cmd.Flags().IntVarP(&opts.runs, "runs", "n", 10, "Number of recent runs of the daemon to show")
+	cmd.Flags().StringVarP(&opts.dest, "dest", "d", "", "Root directory that would be sorted into (defaults to the directory being checked)")
+	cmd.Flags().StringVar(&opts.template, "template", "", "Template for the path of sorted files, e.g. \"{category}/{year}/{name}\"")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "text", "Output format: text, or json")
+}
+
+func newStatusCommand(opts *statusCmdOptions) *cobra.Command {
+	return &cobra.Command{
+
+		Use:   "status [path]",
+		Short: "This command will show how untidy a directory is.",
+		Long: `Reports how many loose files a sort would move into each category, whether
+the scaffolding is missing or only partly there, files sitting in the wrong
+category folder according to the current rules, and any sort or undo left
+pending in the journal. Nothing is changed, it is a quick health check before
+running anything.
+
+With --daemon, asks the running daemon for its jobs, whether its watchers are
+paused, and the results of its most recent runs instead.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// every failure has been reported by now, the exit code tells scripts
+			// that something went wrong.
+			if opts.daemon {
+				if len(args) > 0 {
+					fmt.Println("error: --daemon does not take a path")
+					os.Exit(1)
+				}
+				if err := runDaemonStatus(opts); err != nil {
+					os.Exit(1)
+				}
+				return
+			}
+			if opts.output == "json" {
+				zerolog.SetGlobalLevel(zerolog.Disabled)
+			}
+			if err := runStatus(opts, args); err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+}
+
+// runStatus prints the health report of the directory, returning the error it
+// could not be checked with, if any.
+func runStatus(opts *statusCmdOptions, args []string) error {
+	if opts.output != "text" && opts.output != "json" {
+		err := fmt.Errorf("unknown output %q, expected text or json", opts.output)
+		fmt.Printf("error: %s\n", err)
+		return err
+	}
+
+	Tidy, err := newTidy(false)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		return err
+	}
+	defer Tidy.Close()
+
+	if opts.dest != "" {
+		if err := Tidy.ChangeDestDir(opts.dest); err != nil {
+			fmt.Printf("error: %s\n", err)
+			return err
+		}
+	}
+
+	if opts.template != "" {
+		pt, err := tidy.ParsePathTemplate(opts.template)
+		if err != nil {
+			fmt.Printf("error: %s\n", err)
+			return err
+		}
+		Tidy.Template = pt
+	}
+
+	// status only reads the directory, so protected ones can be looked at.
+	Tidy.Force = true
+
+	if len(args) == 1 {
+		if err := Tidy.ChangeSortDir(args[0]); err != nil {
+			fmt.Printf("error: %s\n", err)
+			return err
+		}
+	}
+	status, err := Tidy.Status()
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		return err
+	}
+
+	if opts.output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(status); err != nil {
+			fmt.Printf("error: %s\n", err)
+			return err
+		}
+		return nil
+	}
+	printStatus(status)
+	return nil
+}
+
+// printStatus prints the health report of a directory.
+func printStatus(status *tidy.Status) {
+	fmt.Printf("%s\n\n", status.SortDir)
+	if status.Clean() {
+		fmt.Println("Nothing to do, the directory is tidy.")
+	}
+
+	if len(status.Loose) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "LOOSE\tFILES\tSIZE\t")
+		files := 0
+		for _, c := range status.Loose {
+			fmt.Fprintf(w, "%s\t%d\t%s\t\n", c.Name, c.Files, formatBytes(c.Bytes))
+			files += c.Files
+		}
+		fmt.Fprintf(w, "Total\t%d\t\t\n", files)
+		w.Flush()
+		fmt.Println()
+	}
+
+	switch status.Scaffolding {
+	case tidy.ScaffoldingPartial:
+		fmt.Println("The scaffolding is only partly present, missing:")
+	case tidy.ScaffoldingMissing:
+		fmt.Println("The scaffolding is missing:")
+	}
+	for _, dir := range status.MissingFolders {
+		fmt.Printf("  %s\n", dir)
+	}
+	if len(status.MissingFolders) > 0 {
+		fmt.Println()
+	}
+
+	if len(status.Misplaced) > 0 {
+		fmt.Println("Files in the wrong folder:")
+		for _, m := range status.Misplaced {
+			fmt.Printf("  %s (belongs in %s)\n", m.Path, m.Want)
+		}
+		fmt.Println()
+	}
+
+	if len(status.Clashes) > 0 {
+		fmt.Println("Files in the way of a sorting folder:")
+		for _, c := range status.Clashes {
+			switch c.Policy {
+			case tidy.ClashRenameFolder:
+				fmt.Printf("  %s (%s is used instead)\n", c.Path, c.Dest)
+			case tidy.ClashRename:
+				fmt.Printf("  %s (renamed to %s)\n", c.Path, c.Dest)
+			default:
+				fmt.Printf("  %s (sorted to %s)\n", c.Path, c.Dest)
+			}
+		}
+		fmt.Println()
+	}
+
+	for _, p := range status.Pending {
+		started := p.Started.Format("2006-01-02 15:04:05")
+		switch p.State {
+		case tidy.RunInterrupted:
+			fmt.Printf("The sort started at %s was interrupted after %d moves, run tidy undo to revert it.\n", started, p.Moves)
+		case tidy.RunPartlyUndone:
+			fmt.Printf("The undo of the sort started at %s was interrupted, run tidy undo again to finish it.\n", started)
+		case tidy.RunUndoable:
+			fmt.Printf("The sort started at %s moved %d files, and can be undone.\n", started, p.Moves)
+		}
+	}
+}
+
+// runDaemonStatus prints the jobs and recent runs of the daemon, returning the
+// error it could not be reached with, if any.
+func runDaemonStatus(opts *statusCmdOptions) error {
+	status, err := daemon.NewClient(rootOpts.socket).Status()
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	watchers := "running"
+	if status.Paused {
+		watchers = "paused"
+	}
+	fmt.Fprintf(w, "Watchers:\t%s\n\n", watchers)
+
+	fmt.Fprintln(w, "JOB\tDIR\tSCHEDULE\tWATCH")
+	for _, job := range status.Jobs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", job.Name, job.Dir, job.Schedule, job.Watch)
+	}
+
+	runs := status.Runs
+	if opts.runs >= 0 && len(runs) > opts.runs {
+		runs = runs[len(runs)-opts.runs:]
+	}
+	fmt.Fprintln(w, "\nSTARTED\tJOB\tTOOK\tRESULT")
+	for _, run := range runs {
+		result := "ok"
+		if run.Result != nil {
+			result = fmt.Sprintf("moved %d", run.Result.Moved)
+		}
+		if run.Error != "" {
+			result = run.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", run.Started.Format("2006-01-02 15:04:05"), run.Job, run.Finished.Sub(run.Started).Round(time.Millisecond), result)
+	}
+	return nil
+}
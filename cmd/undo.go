@@ -4,12 +4,17 @@ Copyright © 2023 DUEX COAST duexcoast@gmail.com
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/duexcoast/tidy-up/pkg/logger"
 	"github.com/duexcoast/tidy-up/pkg/tidy"
 	"github.com/joho/godotenv"
-	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 )
 
@@ -17,6 +22,7 @@ type undoCmdOptions struct {
 	sortType string
 	verbose  bool
 	envFiles []string
+	wait     time.Duration
 }
 
 func init() {
@@ -25,6 +31,7 @@ func init() {
 	rootCmd.AddCommand(cmd)
 
 	cmd.Flags().StringVarP(&opts.sortType, "type", "t", "filetypeSorter", "The sort type to be used")
+	cmd.Flags().DurationVar(&opts.wait, "wait", 0, "How long to wait for a sort of the directory to finish, negative to wait until it does")
 	cmd.PersistentFlags().BoolVarP(&opts.verbose, "verbose", "v", false, "verbose output")
 
 	cmd.PersistentFlags().StringSliceVar(&opts.envFiles, "env-file", []string{}, "Env files to parse environment variables (looks for .env by default).")
@@ -44,28 +51,80 @@ func newUndoCommand(opts *undoCmdOptions) *cobra.Command {
 			if err != nil {
 				l.Error().Err(err).Msg("error loading env files.")
 			}
-			runUndo(opts, args)
+			// every failure has been reported by now, the exit code tells scripts
+			// that something went wrong.
+			if err := runUndo(opts, args); err != nil {
+				os.Exit(1)
+			}
 		},
 	}
 }
 
-func runUndo(opts *undoCmdOptions, args []string) {
-	flags := &tidy.TidyFlags{Verbose: opts.verbose}
-	Tidy, err := tidy.NewTidy(tidy.NewFiletypeSorter(), flags, afero.NewOsFs())
+// runUndo undoes the sorts of the directory, returning the error the undo failed
+// with, if any. An interrupt stops the undo before the next file, and the number
+// of sorts which are still to be undone is printed.
+func runUndo(opts *undoCmdOptions, args []string) error {
+	Tidy, err := newTidy(opts.verbose)
 	if err != nil {
 		fmt.Printf("error: %s\n", err)
+		return err
+	}
+	defer Tidy.Close()
+
+	if opts.wait != 0 {
+		Tidy.LockWait = opts.wait
 	}
 
+	// undo brings files back to where they were, which is never refused.
+	Tidy.Force = true
+
 	// arg is path of directory to be unsorted
 	if len(args) == 1 {
 		err := Tidy.ChangeSortDir(args[0])
 		if err != nil {
 			fmt.Printf("error: %s\n", err)
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	before := pendingSorts(Tidy)
+	err = Tidy.UndoContext(ctx)
+	if errors.Is(err, context.Canceled) {
+		left := pendingSorts(Tidy)
+		if before == 0 {
+			// the directory was sorted before it had a journal.
+			fmt.Println("Cancelled, some files are still in the sorting folders. Undo again to bring them back.")
+			return err
 		}
+		fmt.Printf("Cancelled, undid %d of %d sorts. Undo again to bring back the files of the other %d.\n", before-left, before, left)
+		return err
 	}
-	err = Tidy.Undo()
 	if err != nil {
 		fmt.Printf("error: %s\n", err)
 	}
+	return err
+}
 
+// pendingSorts returns the number of sorts in the journal of the SortDir which
+// have not been undone.
+func pendingSorts(t *tidy.Tidy) int {
+	entries, err := t.Journal()
+	if err != nil {
+		return 0
+	}
+	undone := make(map[string]bool)
+	for _, e := range entries {
+		if _, ok := undone[e.Run]; !ok || e.Op == tidy.JournalUndone {
+			undone[e.Run] = e.Op == tidy.JournalUndone
+		}
+	}
+	n := 0
+	for _, v := range undone {
+		if !v {
+			n++
+		}
+	}
+	return n
 }
@@ -0,0 +1,105 @@
+/*
+Copyright © 2023 DUEX COAST duexcoast@gmail.com
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/duexcoast/tidy-up/pkg/logger"
+	"github.com/duexcoast/tidy-up/pkg/tidy"
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+)
+
+type watchCmdOptions struct {
+	verbose  bool
+	envFiles []string
+	dest     string
+	settle   time.Duration
+	force    bool
+}
+
+func init() {
+	opts := &watchCmdOptions{}
+	cmd := newWatchCommand(opts)
+	rootCmd.AddCommand(cmd)
+
+	cmd.Flags().StringVarP(&opts.dest, "dest", "d", "", "Root directory to sort into (defaults to the watched directory itself)")
+	cmd.Flags().DurationVar(&opts.settle, "settle", tidy.DefaultSettle, "How long a new file has to stay unchanged before it is sorted")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "Watch the directory even if it is protected, such as the home directory or a git repository")
+	cmd.PersistentFlags().BoolVarP(&opts.verbose, "verbose", "v", false, "verbose output")
+
+	cmd.PersistentFlags().StringSliceVar(&opts.envFiles, "env-file", []string{}, "Env files to parse environment variables (looks for .env by default).")
+}
+
+func newWatchCommand(opts *watchCmdOptions) *cobra.Command {
+	return &cobra.Command{
+
+		Use:     "watch [path]",
+		Aliases: []string{"w"},
+		Short:   "This command will sort new files in the specified directory as they appear.",
+		Long: `Watches the specified directory, or the current directory, and sorts every
+new file as soon as it has settled, using the same sorter and config as sort.
+Runs until interrupted.`,
+		Args: cobra.RangeArgs(0, 1),
+		Run: func(cmd *cobra.Command, args []string) {
+			l := logger.Get()
+			err := godotenv.Load(opts.envFiles...)
+			if err != nil {
+				l.Error().Err(err).Msg("error loading env files.")
+			}
+			// the exit code tells a service manager that the watcher failed.
+			if err := runWatch(opts, args); err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+}
+
+// runWatch sorts new files in the directory until interrupted, returning the
+// error the watcher failed with, if any.
+func runWatch(opts *watchCmdOptions, args []string) error {
+	Tidy, err := newTidy(opts.verbose)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		return err
+	}
+	defer Tidy.Close()
+
+	if opts.dest != "" {
+		if err := Tidy.ChangeDestDir(opts.dest); err != nil {
+			fmt.Printf("error: %s\n", err)
+			return err
+		}
+	}
+
+	if opts.force {
+		Tidy.Force = true
+	}
+
+	// arg is path of directory to be watched
+	if len(args) == 1 {
+		err := Tidy.ChangeSortDir(args[0])
+		if err != nil {
+			printError(err)
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	w := Tidy.NewWatcher()
+	w.Settle = opts.settle
+	if err := w.Run(ctx); err != nil {
+		printError(err)
+		return err
+	}
+	return nil
+}
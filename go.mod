@@ -3,19 +3,22 @@ module github.com/duexcoast/tidy-up
 go 1.19
 
 require (
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/google/go-cmp v0.5.9
 	github.com/joho/godotenv v1.5.1
+	github.com/mattn/go-isatty v0.0.19
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/rs/zerolog v1.29.1
 	github.com/spf13/afero v1.9.5
 	github.com/spf13/cobra v1.7.0
 	golang.org/x/exp v0.0.0-20230801115018-d63ba01acd4b
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.19 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	golang.org/x/sys v0.10.0 // indirect
@@ -0,0 +1,205 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/duexcoast/tidy-up/pkg/tidy"
+)
+
+// SortRequest is the body of a request to sort, naming either a job of the
+// config or an absolute directory.
+type SortRequest struct {
+	Job string `json:"job,omitempty"`
+	Dir string `json:"dir,omitempty"`
+}
+
+// apiError is the body of every response with an error status.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// DefaultSocketPath returns the path of the Unix socket the daemon listens on,
+// tidy/daemon.sock inside $XDG_RUNTIME_DIR, or inside a directory of the user in
+// the temporary directory when it is not set.
+func DefaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "tidy", "daemon.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("tidy-%d", os.Getuid()), "daemon.sock")
+}
+
+// Handler returns the control API of the Daemon. Every endpoint speaks JSON:
+//
+//	GET  /status           the jobs, whether watchers are paused and recent runs
+//	GET  /runs             the recent runs
+//	POST /sort             sort a job or a directory, see SortRequest
+//	POST /pause            pause the watchers
+//	POST /resume           resume the watchers
+//	GET  /journal?dir=...  the journal of a directory, or of a job with ?job=...
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", d.handle(http.MethodGet, func(r *http.Request) (any, error) {
+		return d.Status(), nil
+	}))
+	mux.HandleFunc("/runs", d.handle(http.MethodGet, func(r *http.Request) (any, error) {
+		return d.Runs(), nil
+	}))
+	mux.HandleFunc("/sort", d.handle(http.MethodPost, func(r *http.Request) (any, error) {
+		var req SortRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, badRequest(err)
+		}
+		switch {
+		case req.Job != "":
+			run, err := d.RunJob(req.Job)
+			if err != nil {
+				return nil, badRequest(err)
+			}
+			return run, nil
+		case filepath.IsAbs(req.Dir):
+			return d.SortDir(req.Dir), nil
+		default:
+			return nil, badRequest(errors.New("a job or an absolute dir is required"))
+		}
+	}))
+	mux.HandleFunc("/pause", d.handle(http.MethodPost, func(r *http.Request) (any, error) {
+		d.Pause()
+		return d.Status(), nil
+	}))
+	mux.HandleFunc("/resume", d.handle(http.MethodPost, func(r *http.Request) (any, error) {
+		d.Resume()
+		return d.Status(), nil
+	}))
+	mux.HandleFunc("/journal", d.handle(http.MethodGet, func(r *http.Request) (any, error) {
+		dir := r.URL.Query().Get("dir")
+		if name := r.URL.Query().Get("job"); name != "" {
+			job, ok := d.job(name)
+			if !ok {
+				return nil, badRequest(fmt.Errorf("no job named %q", name))
+			}
+			dir = d.resolve(job.Dir)
+		}
+		if !filepath.IsAbs(dir) && !(dir != "" && dir[0] == '~') {
+			return nil, badRequest(errors.New("a job or an absolute dir is required"))
+		}
+		return d.Journal(dir)
+	}))
+	return mux
+}
+
+// job returns the job called name.
+func (d *Daemon) job(name string) (job tidy.JobConfig, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	job, ok = d.jobs[name]
+	return job, ok
+}
+
+// requestError is an error caused by the request itself rather than the Daemon.
+type requestError struct {
+	err error
+}
+
+func (e *requestError) Error() string { return e.err.Error() }
+
+func badRequest(err error) error {
+	return &requestError{err}
+}
+
+// handle wraps an endpoint which only accepts method, encoding its result or
+// its error as JSON.
+func (d *Daemon) handle(method string, endpoint func(r *http.Request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(apiError{Error: fmt.Sprintf("%s %s is not allowed", r.Method, r.URL.Path)})
+			return
+		}
+
+		v, err := endpoint(r)
+		if err != nil {
+			status := http.StatusInternalServerError
+			var reqErr *requestError
+			if errors.As(err, &reqErr) {
+				status = http.StatusBadRequest
+			}
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(apiError{Error: err.Error()})
+			return
+		}
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			d.logger.Error().Err(err).Str("Path", r.URL.Path).Msg("Could not write response.")
+		}
+	}
+}
+
+// Serve serves the control API on the Unix socket at path until ctx is
+// cancelled. The socket is only accessible by the user running the Daemon.
+func (d *Daemon) Serve(ctx context.Context, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	// a socket left behind by a daemon which did not shut down cleanly makes
+	// Listen fail, but one which still answers belongs to a running daemon.
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	l, err := listen(path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	d.logger.Info().Str("Socket", path).Msg("Serving control API.")
+
+	srv := &http.Server{Handler: d.Handler()}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// listen creates the Unix socket at path, accessible only by the user. The
+// directory of path may be shared, so the socket is created in a directory of
+// its own which only the user can access, and only moved to path once its
+// permissions are restricted.
+func listen(path string) (*net.UnixListener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".tidy-socket-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: filepath.Join(dir, "daemon.sock"), Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// the socket is moved, Serve removes it from path once done.
+	l.SetUnlinkOnClose(false)
+	if err := os.Chmod(filepath.Join(dir, "daemon.sock"), 0o600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if err := os.Rename(filepath.Join(dir, "daemon.sock"), path); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
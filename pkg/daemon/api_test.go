@@ -0,0 +1,121 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/duexcoast/tidy-up/pkg/tidy"
+)
+
+func TestControlAPI(t *testing.T) {
+	t.Log("Given the need to control a running daemon over its Unix socket.")
+
+	testID := 0
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create a temporary directory: %v", failed, testID, err)
+	}
+	inbox := filepath.Join(dir, "inbox")
+	if err := os.Mkdir(inbox, 0o755); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create the inbox: %v", failed, testID, err)
+	}
+	if err := os.WriteFile(filepath.Join(inbox, "story.txt"), []byte("tidy"), 0o644); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create story.txt: %v", failed, testID, err)
+	}
+
+	cfg := &tidy.Config{Jobs: []tidy.JobConfig{{Name: "inbox", Dir: inbox, Watch: true}}}
+	d, err := New(func() (*tidy.Config, error) { return cfg, nil })
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create a Daemon: %v", failed, testID, err)
+	}
+	if err := d.Start(); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to start the Daemon: %v", failed, testID, err)
+	}
+	defer d.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	socket := filepath.Join(dir, "daemon.sock")
+	go d.Serve(ctx, socket)
+
+	c := NewClient(socket)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := c.Status(); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to reach the control API: %v", failed, testID, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Logf("\tTest %d:\tWhen sorting a directory through the API.", testID)
+	{
+		run, err := c.SortDir(inbox)
+		if err != nil || run.Error != "" {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to sort the inbox: %v %s", failed, testID, err, run.Error)
+		}
+		if _, err := os.Stat(filepath.Join(inbox, "Documents", "story.txt")); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould have sorted story.txt into the Documents folder: %v", failed, testID, err)
+		}
+		runs, err := c.Runs()
+		if err != nil || len(runs) != 1 {
+			t.Fatalf("\t%s\tTest %d:\tShould list the run, got %v: %v", failed, testID, runs, err)
+		}
+		entries, err := c.Journal(inbox)
+		if err != nil || len(entries) == 0 {
+			t.Fatalf("\t%s\tTest %d:\tShould return the journal of the inbox, got %v: %v", failed, testID, entries, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould sort the directory, and report the run and the journal.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen pausing and resuming the watchers.", testID)
+	{
+		status, err := c.Pause()
+		if err != nil || !status.Paused {
+			t.Fatalf("\t%s\tTest %d:\tShould pause the watchers, got %+v: %v", failed, testID, status, err)
+		}
+		status, err = c.Resume()
+		if err != nil || status.Paused {
+			t.Fatalf("\t%s\tTest %d:\tShould resume the watchers, got %+v: %v", failed, testID, status, err)
+		}
+		if len(status.Jobs) != 1 || status.Jobs[0].Name != "inbox" {
+			t.Fatalf("\t%s\tTest %d:\tShould list the jobs, got %+v", failed, testID, status.Jobs)
+		}
+		t.Logf("\t%s\tTest %d:\tShould report the paused state of the watchers.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen sending an invalid request.", testID)
+	{
+		if _, err := c.SortDir("relative/dir"); err == nil {
+			t.Fatalf("\t%s\tTest %d:\tShould have rejected a relative directory.", failed, testID)
+		}
+		if _, err := c.RunJob("missing"); err == nil {
+			t.Fatalf("\t%s\tTest %d:\tShould have rejected an unknown job.", failed, testID)
+		}
+		t.Logf("\t%s\tTest %d:\tShould return an error.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen looking at the socket.", testID)
+	{
+		info, err := os.Stat(socket)
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to stat the socket: %v", failed, testID, err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Fatalf("\t%s\tTest %d:\tShould only be accessible by the user, got %v", failed, testID, info.Mode())
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) != 2 {
+			t.Fatalf("\t%s\tTest %d:\tShould only leave the socket next to the inbox, got %v: %v", failed, testID, entries, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould only be accessible by the user.", success, testID)
+	}
+}
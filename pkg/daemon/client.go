@@ -0,0 +1,107 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/duexcoast/tidy-up/pkg/tidy"
+)
+
+// Client talks to a running Daemon over the Unix socket of its control API.
+type Client struct {
+	http *http.Client
+}
+
+// NewClient returns a Client for the Daemon listening on the Unix socket at path.
+func NewClient(path string) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}
+	return &Client{http: &http.Client{Transport: transport}}
+}
+
+// Status returns the status of the Daemon.
+func (c *Client) Status() (*Status, error) {
+	status := &Status{}
+	return status, c.do(http.MethodGet, "/status", nil, status)
+}
+
+// Runs returns the recent runs of the Daemon.
+func (c *Client) Runs() ([]Run, error) {
+	var runs []Run
+	return runs, c.do(http.MethodGet, "/runs", nil, &runs)
+}
+
+// RunJob runs the job called name, and returns the result once it is done.
+func (c *Client) RunJob(name string) (Run, error) {
+	var run Run
+	return run, c.do(http.MethodPost, "/sort", SortRequest{Job: name}, &run)
+}
+
+// SortDir sorts dir, which has to be absolute, and returns the result once it is
+// done.
+func (c *Client) SortDir(dir string) (Run, error) {
+	var run Run
+	return run, c.do(http.MethodPost, "/sort", SortRequest{Dir: dir}, &run)
+}
+
+// Pause pauses the watchers of the Daemon.
+func (c *Client) Pause() (*Status, error) {
+	status := &Status{}
+	return status, c.do(http.MethodPost, "/pause", nil, status)
+}
+
+// Resume resumes the watchers of the Daemon.
+func (c *Client) Resume() (*Status, error) {
+	status := &Status{}
+	return status, c.do(http.MethodPost, "/resume", nil, status)
+}
+
+// Journal returns the journal of dir, which has to be absolute.
+func (c *Client) Journal(dir string) ([]tidy.JournalEntry, error) {
+	var entries []tidy.JournalEntry
+	return entries, c.do(http.MethodGet, "/journal?dir="+url.QueryEscape(dir), nil, &entries)
+}
+
+// do sends a request with body encoded as JSON, and decodes the response into
+// out.
+func (c *Client) do(method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	// the host is ignored, the transport always dials the socket.
+	req, err := http.NewRequest(method, "http://tidy"+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not reach the daemon, is tidy daemon running? %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr apiError
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			return fmt.Errorf("daemon responded with %s", resp.Status)
+		}
+		return fmt.Errorf("daemon: %s", apiErr.Error)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
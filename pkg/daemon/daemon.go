@@ -0,0 +1,389 @@
+// Package daemon runs the jobs of the tidy config on their cron schedules, in a
+// single long running process, and exposes a control API to talk to it.
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/duexcoast/tidy-up/pkg/logger"
+	"github.com/duexcoast/tidy-up/pkg/tidy"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog"
+	"github.com/spf13/afero"
+)
+
+// maxRuns is the number of finished runs a Daemon remembers.
+const maxRuns = 100
+
+// Run is the result of a single run of a job.
+type Run struct {
+	Job      string    `json:"job"`
+	Dir      string    `json:"dir"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Error    string    `json:"error,omitempty"`
+
+	// Result reports what the sort did, it is nil if the sort could not start.
+	Result *tidy.Result `json:"result,omitempty"`
+}
+
+// Status describes what a Daemon is doing.
+type Status struct {
+	Jobs   []tidy.JobConfig `json:"jobs"`
+	Paused bool             `json:"paused"`
+	Runs   []Run            `json:"runs"`
+}
+
+// Daemon sorts the directories listed as jobs in the config, each on its own
+// schedule, and watches the directories of jobs which ask for it. It is created
+// with New, and runs from Start until Stop.
+type Daemon struct {
+	load func() (*tidy.Config, error)
+
+	// baseDir is the working directory the Daemon was started in. Relative paths
+	// in the config are resolved against it.
+	baseDir string
+
+	// mu guards the fields below it.
+	mu            sync.Mutex
+	cron          *cron.Cron
+	cfg           *tidy.Config
+	jobs          map[string]tidy.JobConfig
+	runs          []Run
+	watchers      []watch
+	stopWatchers  context.CancelFunc
+	watchersGroup sync.WaitGroup
+	paused        bool
+
+	// sorts is the context of every sort, which is cancelled once the Daemon
+	// stops, so that a long sort does not hold up Stop or Reload.
+	sorts       context.Context
+	cancelSorts context.CancelFunc
+
+	// dirLocks holds a lock for every directory the Daemon sorts, so that a
+	// directory is only sorted by one job or watcher at a time, while different
+	// directories are sorted at the same time.
+	dirLocks map[string]*sync.Mutex
+
+	logger zerolog.Logger
+}
+
+// New returns a Daemon which reads its config with load, once when it is started
+// and again on every Reload.
+func New(load func() (*tidy.Config, error)) (*Daemon, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	d := &Daemon{load: load, baseDir: wd, dirLocks: make(map[string]*sync.Mutex), logger: logger.Get()}
+	d.sorts, d.cancelSorts = context.WithCancel(context.Background())
+	return d, nil
+}
+
+// Start loads the config, schedules its jobs and starts watching the directories
+// of the jobs which ask for it. Jobs keep running in the background until Stop
+// is called.
+func (d *Daemon) Start() error {
+	return d.Reload()
+}
+
+// Reload reads the config again and replaces the scheduled jobs and watchers
+// with the ones it lists. If the new config is invalid, an error is returned and
+// the jobs that were already scheduled keep running.
+func (d *Daemon) Reload() error {
+	cfg, err := d.load()
+	if err != nil {
+		return err
+	}
+
+	c := cron.New()
+	jobs := make(map[string]tidy.JobConfig, len(cfg.Jobs))
+	var watchers []watch
+	// closeWatchers closes the watchers built so far, which were never started,
+	// when the config turns out to be invalid.
+	closeWatchers := func() {
+		for _, w := range watchers {
+			w.tidy.Close()
+		}
+	}
+	for _, job := range cfg.Jobs {
+		if job.Dir == "" {
+			closeWatchers()
+			return fmt.Errorf("job %q: no dir", job.Name)
+		}
+		if job.Name == "" {
+			job.Name = job.Dir
+		}
+		if _, ok := jobs[job.Name]; ok {
+			closeWatchers()
+			return fmt.Errorf("job %q: defined more than once", job.Name)
+		}
+		if job.Schedule == "" && !job.Watch {
+			closeWatchers()
+			return fmt.Errorf("job %q: no schedule", job.Name)
+		}
+		if job.Schedule != "" {
+			name := job.Name
+			if _, err := c.AddFunc(job.Schedule, func() { d.RunJob(name) }); err != nil {
+				closeWatchers()
+				return fmt.Errorf("job %q: invalid schedule %q: %w", job.Name, job.Schedule, err)
+			}
+		}
+		if job.Watch {
+			w, err := d.newWatcher(cfg, job)
+			if err != nil {
+				closeWatchers()
+				return fmt.Errorf("job %q: %w", job.Name, err)
+			}
+			watchers = append(watchers, w)
+		}
+		jobs[job.Name] = job
+	}
+
+	d.stop()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cron, d.cfg, d.jobs = c, cfg, jobs
+	d.sorts, d.cancelSorts = context.WithCancel(context.Background())
+	d.startWatchers(watchers)
+	c.Start()
+	d.logger.Info().Int("Jobs", len(jobs)).Int("Watchers", len(watchers)).Msg("Scheduled jobs.")
+	return nil
+}
+
+// Stop stops scheduling jobs and watching directories. Sorts which are already
+// running are cancelled, and Stop waits for the moves they started to finish.
+func (d *Daemon) Stop() {
+	d.stop()
+}
+
+func (d *Daemon) stop() {
+	d.mu.Lock()
+	c, stopWatchers := d.cron, d.stopWatchers
+	d.cron, d.stopWatchers, d.watchers = nil, nil, nil
+	d.cancelSorts()
+	d.mu.Unlock()
+
+	if stopWatchers != nil {
+		stopWatchers()
+		d.watchersGroup.Wait()
+	}
+	if c != nil {
+		<-c.Stop().Done()
+	}
+}
+
+// startWatchers runs watchers in the background, paused if the Daemon is. The
+// caller must hold d.mu.
+func (d *Daemon) startWatchers(watchers []watch) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.watchers, d.stopWatchers = watchers, cancel
+	for _, w := range watchers {
+		if d.paused {
+			w.watcher.Pause()
+		}
+		d.watchersGroup.Add(1)
+		go func(w watch) {
+			defer d.watchersGroup.Done()
+			defer w.tidy.Close()
+			if err := w.watcher.Run(ctx); err != nil {
+				d.logger.Error().Err(err).Msg("Watcher stopped.")
+			}
+		}(w)
+	}
+}
+
+// Pause pauses the watchers, files which appear in the meantime are sorted once
+// they are resumed. Scheduled jobs keep running.
+func (d *Daemon) Pause() {
+	d.setPaused(true)
+}
+
+// Resume resumes the watchers after a Pause.
+func (d *Daemon) Resume() {
+	d.setPaused(false)
+}
+
+func (d *Daemon) setPaused(paused bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.paused = paused
+	for _, w := range d.watchers {
+		if paused {
+			w.watcher.Pause()
+		} else {
+			w.watcher.Resume()
+		}
+	}
+}
+
+// RunJob runs the job called name right away, and returns the result of the
+// run. The returned error is only non-nil if there is no such job, errors while
+// sorting are reported in the Run.
+func (d *Daemon) RunJob(name string) (Run, error) {
+	d.mu.Lock()
+	job, ok := d.jobs[name]
+	cfg := d.cfg
+	d.mu.Unlock()
+	if !ok {
+		return Run{}, fmt.Errorf("no job named %q", name)
+	}
+	return d.run(cfg, job), nil
+}
+
+// SortDir sorts dir right away using the config of the Daemon, as if it was the
+// directory of a job, and returns the result of the run.
+func (d *Daemon) SortDir(dir string) Run {
+	d.mu.Lock()
+	cfg := d.cfg
+	d.mu.Unlock()
+	return d.run(cfg, tidy.JobConfig{Name: dir, Dir: dir})
+}
+
+// Journal returns the journal of dir, see Tidy.Journal.
+func (d *Daemon) Journal(dir string) ([]tidy.JournalEntry, error) {
+	// reading the journal moves nothing, so it is read even for directories
+	// which are protected from being sorted.
+	t, err := d.newTidy(&tidy.Config{}, tidy.JobConfig{Dir: dir, Force: true})
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+	return t.Journal()
+}
+
+// Runs returns the most recent runs of all jobs, oldest first.
+func (d *Daemon) Runs() []Run {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Run(nil), d.runs...)
+}
+
+// Jobs returns the jobs which are currently scheduled, ordered by name.
+func (d *Daemon) Jobs() []tidy.JobConfig {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	jobs := make([]tidy.JobConfig, 0, len(d.jobs))
+	for _, job := range d.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs
+}
+
+// Status returns the jobs, whether the watchers are paused and the recent runs.
+func (d *Daemon) Status() Status {
+	jobs, runs := d.Jobs(), d.Runs()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return Status{Jobs: jobs, Paused: d.paused, Runs: runs}
+}
+
+// run sorts the directory of job, and records and logs the result.
+func (d *Daemon) run(cfg *tidy.Config, job tidy.JobConfig) Run {
+	run := Run{Job: job.Name, Dir: d.resolve(job.Dir), Started: time.Now()}
+	result, err := d.sort(cfg, job)
+	run.Result = result
+	run.Finished = time.Now()
+	if err != nil {
+		run.Error = err.Error()
+		d.logger.Error().Err(err).Str("Job", job.Name).Str("Directory", run.Dir).Msg("Job failed.")
+	} else {
+		d.logger.Info().Str("Job", job.Name).Str("Directory", run.Dir).Dur("Took", run.Finished.Sub(run.Started)).Msg("Job finished.")
+	}
+
+	d.mu.Lock()
+	d.runs = append(d.runs, run)
+	if len(d.runs) > maxRuns {
+		d.runs = d.runs[len(d.runs)-maxRuns:]
+	}
+	d.mu.Unlock()
+	return run
+}
+
+// sort sorts the directory of job according to the config and the job.
+func (d *Daemon) sort(cfg *tidy.Config, job tidy.JobConfig) (*tidy.Result, error) {
+	t, err := d.newTidy(cfg, job)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+
+	d.mu.Lock()
+	ctx := d.sorts
+	d.mu.Unlock()
+
+	lock := d.dirLock(t.SortDir)
+	lock.Lock()
+	defer lock.Unlock()
+	return t.SortContext(ctx)
+}
+
+// dirLock returns the lock of the directory dir.
+func (d *Daemon) dirLock(dir string) *sync.Mutex {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	lock, ok := d.dirLocks[dir]
+	if !ok {
+		lock = &sync.Mutex{}
+		d.dirLocks[dir] = lock
+	}
+	return lock
+}
+
+// watch is a Watcher along with the Tidy it sorts with, which has to be closed
+// once the Watcher stops.
+type watch struct {
+	tidy    *tidy.Tidy
+	watcher *tidy.Watcher
+}
+
+// newWatcher returns a Watcher for the directory of job, which shares the lock
+// of the directory with every other sort of the Daemon.
+func (d *Daemon) newWatcher(cfg *tidy.Config, job tidy.JobConfig) (watch, error) {
+	t, err := d.newTidy(cfg, job)
+	if err != nil {
+		return watch{}, err
+	}
+	w := t.NewWatcher()
+	w.Lock = d.dirLock(t.SortDir)
+	return watch{tidy: t, watcher: w}, nil
+}
+
+// newTidy returns a Tidy for the directory of job, configured by the config and
+// the job.
+func (d *Daemon) newTidy(cfg *tidy.Config, job tidy.JobConfig) (*tidy.Tidy, error) {
+	t, err := tidy.NewTidy(tidy.NewFiletypeSorter(), &tidy.TidyFlags{}, afero.NewOsFs())
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Apply(t); err != nil {
+		t.Close()
+		return nil, err
+	}
+	if err := job.Apply(t); err != nil {
+		t.Close()
+		return nil, err
+	}
+	if err := t.ChangeSortDir(d.resolve(job.Dir)); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// resolve makes a relative job directory relative to the directory the Daemon
+// was started in. Paths starting with "~" are left for Tidy to expand.
+func (d *Daemon) resolve(dir string) string {
+	if filepath.IsAbs(dir) || dir == "~" || len(dir) > 1 && dir[:2] == "~/" {
+		return dir
+	}
+	return filepath.Join(d.baseDir, dir)
+}
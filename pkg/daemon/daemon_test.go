@@ -0,0 +1,106 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/duexcoast/tidy-up/pkg/tidy"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestDaemon(t *testing.T) {
+	t.Log("Given the need to sort directories on a schedule.")
+
+	testID := 0
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create a temporary directory: %v", failed, testID, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "story.txt"), []byte("tidy"), 0o644); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create story.txt: %v", failed, testID, err)
+	}
+
+	cfg := &tidy.Config{Jobs: []tidy.JobConfig{{Name: "inbox", Dir: dir, Schedule: "@every 1h"}}}
+	d, err := New(func() (*tidy.Config, error) { return cfg, nil })
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create a Daemon: %v", failed, testID, err)
+	}
+	if err := d.Start(); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to start the Daemon: %v", failed, testID, err)
+	}
+	defer d.Stop()
+
+	t.Logf("\tTest %d:\tWhen running a job.", testID)
+	{
+		run, err := d.RunJob("inbox")
+		if err != nil || run.Error != "" {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to run the job: %v %s", failed, testID, err, run.Error)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "Documents", "story.txt")); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould have sorted story.txt into the Documents folder: %v", failed, testID, err)
+		}
+		if runs := d.Runs(); len(runs) != 1 || runs[0].Job != "inbox" {
+			t.Fatalf("\t%s\tTest %d:\tShould have recorded the run, got: %v", failed, testID, runs)
+		}
+		t.Logf("\t%s\tTest %d:\tShould sort the directory of the job and record the run.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen reloading an invalid config.", testID)
+	{
+		cfg = &tidy.Config{Jobs: []tidy.JobConfig{{Name: "broken", Dir: dir, Schedule: "every now and then"}}}
+		if err := d.Reload(); err == nil {
+			t.Fatalf("\t%s\tTest %d:\tShould have rejected the invalid schedule.", failed, testID)
+		}
+		if _, err := d.RunJob("inbox"); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould have kept the previous jobs: %v", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould keep the jobs that were already scheduled.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen running a job that does not exist.", testID)
+	{
+		if _, err := d.RunJob("missing"); err == nil {
+			t.Fatalf("\t%s\tTest %d:\tShould have returned an error.", failed, testID)
+		}
+		t.Logf("\t%s\tTest %d:\tShould return an error.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen reading the journal of a protected directory.", testID)
+	{
+		repo := filepath.Join(dir, "repo")
+		if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o755); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to create the git repository: %v", failed, testID, err)
+		}
+		if _, err := d.Journal(repo); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to read the journal: %v", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould be able to read the journal.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen running a job once the Daemon is stopped.", testID)
+	{
+		if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("tidy"), 0o644); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to create notes.txt: %v", failed, testID, err)
+		}
+		d.Stop()
+		run, err := d.RunJob("inbox")
+		if err != nil || !strings.Contains(run.Error, context.Canceled.Error()) {
+			t.Fatalf("\t%s\tTest %d:\tShould cancel the sort, got %q: %v", failed, testID, run.Error, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould leave notes.txt where it is: %v", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould cancel the sort.", success, testID)
+	}
+}
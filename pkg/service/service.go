@@ -0,0 +1,243 @@
+// Package service installs tidy as a systemd user service, so that the daemon,
+// or a regular sort of a single directory, runs in the background.
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// DefaultName is the name of the units installed by tidy, without their suffix.
+const DefaultName = "tidy"
+
+// Systemctl runs systemctl for the user instance of systemd with args, and
+// returns its combined output.
+type Systemctl func(args ...string) ([]byte, error)
+
+// RunSystemctl runs the systemctl binary of the system.
+func RunSystemctl(args ...string) ([]byte, error) {
+	return exec.Command("systemctl", append([]string{"--user"}, args...)...).CombinedOutput()
+}
+
+// Service describes the systemd units that run tidy. Without a Schedule, a
+// single service runs tidy daemon. With a Schedule, a oneshot service sorts Dir
+// and a timer starts it on the Schedule.
+type Service struct {
+	Fs afero.Fs
+
+	// UnitDir is the directory the unit files are written to, see DefaultUnitDir.
+	UnitDir string
+
+	// Name is the name of the units, without their suffix.
+	Name string
+
+	// Binary is the absolute path of the tidy binary.
+	Binary string
+
+	// Config is the path of the config file passed to tidy, may be empty.
+	Config string
+
+	// Schedule is a systemd calendar expression such as "hourly" or
+	// "*-*-* 02:00:00". When set, Dir is sorted on the Schedule instead of
+	// running the daemon.
+	Schedule string
+
+	// Dir is the directory sorted on the Schedule.
+	Dir string
+
+	Systemctl Systemctl
+}
+
+// DefaultUnitDir returns the directory systemd looks for user units in,
+// systemd/user inside the user config directory.
+func DefaultUnitDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "systemd", "user"), nil
+}
+
+// New returns a Service named DefaultName, for the running binary, which writes
+// its units to unitDir using systemctl of the system.
+func New(fsys afero.Fs, unitDir string) (*Service, error) {
+	binary, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return &Service{Fs: fsys, UnitDir: unitDir, Name: DefaultName, Binary: binary, Systemctl: RunSystemctl}, nil
+}
+
+// Units renders the unit files of the Service, keyed by their file name.
+func (s *Service) Units() (map[string]string, error) {
+	if s.Binary == "" {
+		return nil, errors.New("no tidy binary")
+	}
+	args := []string{s.Binary}
+	if s.Config != "" {
+		args = append(args, "--config", s.Config)
+	}
+
+	if s.Schedule == "" {
+		args = append(args, "daemon")
+		return map[string]string{
+			s.Name + ".service": fmt.Sprintf(`[Unit]
+Description=tidy daemon, sorts directories on a schedule
+
+[Service]
+ExecStart=%s
+ExecReload=/bin/kill -HUP $MAINPID
+Restart=on-failure
+
+[Install]
+WantedBy=default.target
+`, quoteArgs(args)),
+		}, nil
+	}
+
+	if s.Dir == "" {
+		return nil, errors.New("a schedule needs a directory to sort")
+	}
+	args = append(args, "sort", s.Dir)
+	return map[string]string{
+		s.Name + ".service": fmt.Sprintf(`[Unit]
+Description=tidy, sorts %s
+
+[Service]
+Type=oneshot
+ExecStart=%s
+`, s.Dir, quoteArgs(args)),
+		s.Name + ".timer": fmt.Sprintf(`[Unit]
+Description=tidy, sorts %s on a schedule
+
+[Timer]
+OnCalendar=%s
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`, s.Dir, s.Schedule),
+	}, nil
+}
+
+// unit is the unit that is enabled and started, the timer if there is one.
+func (s *Service) unit() string {
+	if s.Schedule != "" {
+		return s.Name + ".timer"
+	}
+	return s.Name + ".service"
+}
+
+// Install writes the unit files, and enables and starts the Service.
+func (s *Service) Install() error {
+	units, err := s.Units()
+	if err != nil {
+		return err
+	}
+	if err := s.Fs.MkdirAll(s.UnitDir, 0o755); err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(units) {
+		if err := afero.WriteFile(s.Fs, filepath.Join(s.UnitDir, name), []byte(units[name]), 0o644); err != nil {
+			return err
+		}
+	}
+
+	if err := s.systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return s.systemctl("enable", "--now", s.unit())
+}
+
+// Uninstall stops and disables the Service, and removes its unit files. Only
+// the units which are installed are touched.
+func (s *Service) Uninstall() error {
+	installed, err := s.installed()
+	if err != nil {
+		return err
+	}
+
+	if err := s.systemctl(append([]string{"disable", "--now"}, installed...)...); err != nil {
+		return err
+	}
+	for _, name := range installed {
+		if err := s.Fs.Remove(filepath.Join(s.UnitDir, name)); err != nil {
+			return err
+		}
+	}
+	return s.systemctl("daemon-reload")
+}
+
+// Status returns the status of the installed units as reported by systemctl.
+func (s *Service) Status() (string, error) {
+	installed, err := s.installed()
+	if err != nil {
+		return "", err
+	}
+
+	// systemctl status exits non-zero for units which are not running, which is
+	// a status like any other.
+	out, err := s.Systemctl(append([]string{"status", "--no-pager"}, installed...)...)
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// installed returns the names of the unit files of the Service that exist, and
+// an error if there are none.
+func (s *Service) installed() ([]string, error) {
+	var names []string
+	for _, suffix := range []string{".timer", ".service"} {
+		name := s.Name + suffix
+		ok, err := afero.Exists(s.Fs, filepath.Join(s.UnitDir, name))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s is not installed in %s", s.Name, s.UnitDir)
+	}
+	return names, nil
+}
+
+func (s *Service) systemctl(args ...string) error {
+	out, err := s.Systemctl(args...)
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// quoteArgs joins args into a command line for ExecStart, quoting arguments
+// which contain spaces.
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, v := range args {
+		if strings.ContainsAny(v, " \t\"\\") {
+			v = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+		}
+		quoted[i] = v
+	}
+	return strings.Join(quoted, " ")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
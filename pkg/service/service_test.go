@@ -0,0 +1,105 @@
+package service
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// fakeSystemctl records the calls made to systemctl instead of running it.
+type fakeSystemctl struct {
+	calls []string
+}
+
+func (f *fakeSystemctl) run(args ...string) ([]byte, error) {
+	f.calls = append(f.calls, strings.Join(args, " "))
+	return []byte("active"), nil
+}
+
+func TestService(t *testing.T) {
+	t.Log("Given the need to run tidy as a systemd user service.")
+
+	unitDir := filepath.Join("home", ".config", "systemd", "user")
+	tests := []struct {
+		name     string
+		schedule string
+		dir      string
+		units    map[string]string
+		enabled  string
+	}{
+		{
+			name:    "daemon",
+			units:   map[string]string{"tidy.service": "ExecStart=/usr/bin/tidy --config \"/home/my config.yaml\" daemon"},
+			enabled: "tidy.service",
+		},
+		{
+			name:     "timer",
+			schedule: "hourly",
+			dir:      "/home/Downloads",
+			units: map[string]string{
+				"tidy.service": "ExecStart=/usr/bin/tidy --config \"/home/my config.yaml\" sort /home/Downloads",
+				"tidy.timer":   "OnCalendar=hourly",
+			},
+			enabled: "tidy.timer",
+		},
+	}
+
+	for testID, test := range tests {
+		t.Logf("\tTest %d:\tWhen installing and uninstalling the %s units.", testID, test.name)
+		{
+			fsys := afero.NewMemMapFs()
+			systemctl := &fakeSystemctl{}
+			s := &Service{
+				Fs:        fsys,
+				UnitDir:   unitDir,
+				Name:      DefaultName,
+				Binary:    "/usr/bin/tidy",
+				Config:    "/home/my config.yaml",
+				Schedule:  test.schedule,
+				Dir:       test.dir,
+				Systemctl: systemctl.run,
+			}
+
+			if err := s.Install(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to install the service: %v", failed, testID, err)
+			}
+			for name, want := range test.units {
+				b, err := afero.ReadFile(fsys, filepath.Join(unitDir, name))
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould have written %s: %v", failed, testID, name, err)
+				}
+				if !strings.Contains(string(b), want) {
+					t.Fatalf("\t%s\tTest %d:\tShould have written %q to %s, got:\n%s", failed, testID, want, name, b)
+				}
+			}
+			if got, want := strings.Join(systemctl.calls, "; "), "daemon-reload; enable --now "+test.enabled; got != want {
+				t.Fatalf("\t%s\tTest %d:\tShould have run systemctl %q, got %q", failed, testID, want, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould write the units and enable %s.", success, testID, test.enabled)
+
+			if _, err := s.Status(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to get the status: %v", failed, testID, err)
+			}
+
+			if err := s.Uninstall(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to uninstall the service: %v", failed, testID, err)
+			}
+			for name := range test.units {
+				if ok, _ := afero.Exists(fsys, filepath.Join(unitDir, name)); ok {
+					t.Fatalf("\t%s\tTest %d:\tShould have removed %s.", failed, testID, name)
+				}
+			}
+			if _, err := s.Status(); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould report that the service is not installed.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould remove the units when uninstalling.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package tidy
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ClashPolicy decides what happens when a file is in the way of a sorting
+// folder, such as a file called "Images" where the Images folder is to be
+// created.
+type ClashPolicy string
+
+const (
+	// ClashSort moves the file into the folder it belongs in, before the
+	// sorting folder is created. This is the default.
+	ClashSort ClashPolicy = "sort"
+
+	// ClashRename renames the file aside by adding a counter to the end of its
+	// name, "Images" becomes "Images-2", before the sorting folder is created.
+	ClashRename ClashPolicy = "rename"
+
+	// ClashRenameFolder leaves the file where it is, and sorts into a folder
+	// with a counter added to its name instead, such as "Images-2".
+	ClashRenameFolder ClashPolicy = "rename-folder"
+)
+
+// ParseClashPolicy converts the name of a clash policy, as used in the config
+// and on the command line, into a ClashPolicy.
+func ParseClashPolicy(s string) (ClashPolicy, error) {
+	switch p := ClashPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case ClashSort, ClashRename, ClashRenameFolder:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown clash policy %q, expected one of sort, rename or rename-folder", s)
+}
+
+// FolderClash is a file in the way of a sorting folder, and what the clash
+// policy does about it.
+type FolderClash struct {
+	// Path is the file in the way of the folder Category.
+	Path     string      `json:"path"`
+	Category string      `json:"category"`
+	Policy   ClashPolicy `json:"policy"`
+
+	// Dest is where a sort moves the file, or with ClashRenameFolder, the folder
+	// which is sorted into instead.
+	Dest string `json:"dest"`
+
+	// into is the folder the file is sorted into with ClashSort.
+	into *FiletypeSortingFolder
+}
+
+// findClash returns the file in the way of folder, and what the clash policy of
+// the run does about it, or nil if nothing is in the way. Nothing is changed on
+// the filesystem, but with ClashRenameFolder the run is set up to sort into the
+// folder which is used instead, so findClash has to be called before anything
+// is sorted into folder.
+func (fts *FiletypeSorter) findClash(r *run, folder *FiletypeSortingFolder) (*FolderClash, error) {
+	path := r.folderPath(folder)
+	info, err := r.fs.Stat(path)
+	if err != nil || info.IsDir() {
+		return nil, nil
+	}
+	fc := &FolderClash{Path: path, Category: folder.Name, Policy: r.onClash}
+
+	switch r.onClash {
+	case ClashRenameFolder:
+		if fc.Dest, err = r.freeName(path, true); err != nil {
+			return nil, err
+		}
+		r.folderAlt[folder.Name] = fc.Dest
+		r.folders[filepath.Base(fc.Dest)] = true
+		r.ignoreDest(path, r.sortDir)
+		r.ignoreDest(fc.Dest, r.sortDir)
+		return fc, nil
+	case ClashRename:
+		if fc.Dest, err = r.freeName(path, false); err != nil {
+			return nil, err
+		}
+		return fc, nil
+	}
+
+	fc.Policy = ClashSort
+	c, err := fts.classify(r, path, info)
+	if err != nil {
+		return nil, err
+	}
+	if c.folder == nil {
+		// the file is never sorted, such as a destination, so it can only be
+		// renamed.
+		fc.Policy = ClashRename
+		if fc.Dest, err = r.freeName(path, false); err != nil {
+			return nil, err
+		}
+		return fc, nil
+	}
+	fc.into = c.folder
+	if fc.Dest, err = r.destination(c.folder, info); err != nil {
+		return nil, err
+	}
+	if _, err := r.fs.Stat(fc.Dest); err == nil {
+		if fc.Dest, err = r.freeName(fc.Dest, false); err != nil {
+			return nil, err
+		}
+	}
+	return fc, nil
+}
+
+// clashes finds the files in the way of the sorting folders, see findClash.
+func (fts *FiletypeSorter) clashes(r *run) ([]FolderClash, error) {
+	if !r.template.groupsByCategory() {
+		return nil, nil
+	}
+	var found []FolderClash
+	for _, v := range fts.Dirs {
+		fc, err := fts.findClash(r, v)
+		if err != nil {
+			return nil, err
+		}
+		if fc != nil {
+			found = append(found, *fc)
+		}
+	}
+	return found, nil
+}
+
+// resolveClash makes way for the sorting folder, if something other than a
+// directory is where it is to be created, by applying the clash policy of the
+// run. Files are moved through the run, so that Undo brings them back.
+//
+// resolving holds the folders whose clashes are being resolved further up the
+// stack, as a file may belong in another folder which has a clash of its own.
+func (fts *FiletypeSorter) resolveClash(r *run, folder *FiletypeSortingFolder, resolving map[*FiletypeSortingFolder]bool) error {
+	fc, err := fts.findClash(r, folder)
+	if err != nil || fc == nil {
+		return err
+	}
+
+	switch fc.Policy {
+	case ClashRename:
+		if err := r.move(fc.Path, fc.Dest); err != nil {
+			return err
+		}
+	case ClashSort:
+		if fc.into != folder && !resolving[fc.into] {
+			resolving[folder] = true
+			err := fts.resolveClash(r, fc.into, resolving)
+			delete(resolving, folder)
+			if err != nil {
+				return err
+			}
+		}
+		src := fc.Path
+		if strings.HasPrefix(fc.Dest, fc.Path+string(filepath.Separator)) {
+			// the file belongs in the very folder it is in the way of, such as
+			// a file called "Other", so it is moved aside until the folder
+			// exists.
+			if src, err = r.freeName(fc.Path, false); err != nil {
+				return err
+			}
+			if err := r.move(fc.Path, src); err != nil {
+				return err
+			}
+		}
+		if err := r.move(src, fc.Dest); err != nil {
+			return err
+		}
+	}
+	r.emit(Event{Type: EventClash, File: filepath.Base(fc.Path), Dest: fc.Dest, Category: folder.Name, Clash: fc.Policy})
+	return nil
+}
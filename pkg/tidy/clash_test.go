@@ -0,0 +1,178 @@
+package tidy
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestSortClash(t *testing.T) {
+	t.Log("Given the need to sort a directory with files in the way of the sorting folders.")
+
+	tests := []struct {
+		policy ClashPolicy
+
+		// sorted are the files expected once sorted, and gone the paths
+		// expected not to exist.
+		sorted []string
+		gone   []string
+	}{
+		{ClashSort, []string{"Images/photo.jpg", "Other/Images", "Other/Other"}, []string{"Images-2"}},
+		{ClashRename, []string{"Images/photo.jpg", "Other/Images-2", "Other/Other-2"}, []string{"Other/Images"}},
+		{ClashRenameFolder, []string{"Images", "Images-2/photo.jpg", "Other", "Other-2"}, []string{"Images/photo.jpg"}},
+	}
+
+	for testID, test := range tests {
+		t.Logf("\tTest %d:\tWhen sorting with the clash policy %s.", testID, test.policy)
+		{
+			Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+			}
+			Tidy.OnClash = test.policy
+			// fsys is the SortDir, where the files of the test live.
+			fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+			for _, name := range []string{"Images", "Other", "photo.jpg"} {
+				if err := afero.WriteFile(fsys, name, []byte(name), 0o644); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+				}
+			}
+
+			result, err := Tidy.Sort()
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to sort: %v", failed, testID, err)
+			}
+			if result.Clashes != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould report 2 clashes, got %d", failed, testID, result.Clashes)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report 2 clashes.", success, testID)
+
+			for _, path := range test.sorted {
+				if _, err := fsys.Stat(path); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould have sorted %s: %v", failed, testID, path, err)
+				}
+			}
+			for _, path := range test.gone {
+				if _, err := fsys.Stat(path); err == nil {
+					t.Fatalf("\t%s\tTest %d:\tShould not have created %s", failed, testID, path)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould resolve the clashes and sort the files.", success, testID)
+
+			if _, err := Tidy.Sort(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to sort again: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to sort again.", success, testID)
+
+			if err := Tidy.Undo(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to undo: %v", failed, testID, err)
+			}
+			for _, name := range []string{"Images", "Other", "photo.jpg"} {
+				b, err := afero.ReadFile(fsys, name)
+				if err != nil || string(b) != name {
+					t.Fatalf("\t%s\tTest %d:\tShould bring %s back, got %q: %v", failed, testID, name, b, err)
+				}
+			}
+			for _, name := range []string{"Images-2", "Other-2"} {
+				if _, err := fsys.Stat(name); err == nil {
+					t.Fatalf("\t%s\tTest %d:\tShould remove %s", failed, testID, name)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould bring every file back once undone.", success, testID)
+		}
+	}
+}
+
+func TestExplainClash(t *testing.T) {
+	t.Log("Given the need to explain a sort with a file in the way of a sorting folder.")
+
+	tests := []struct {
+		policy ClashPolicy
+
+		// cat and images are the destinations expected for cat.jpg and the
+		// file called Images, relative to the SortDir. Empty means not moved.
+		cat    string
+		images string
+	}{
+		{ClashSort, "Images/cat.jpg", "Other/Images"},
+		{ClashRename, "Images/cat.jpg", "Images-2"},
+		{ClashRenameFolder, "Images-2/cat.jpg", ""},
+	}
+
+	for testID, test := range tests {
+		t.Logf("\tTest %d:\tWhen explaining with the clash policy %s.", testID, test.policy)
+		{
+			dir := t.TempDir()
+			Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewOsFs())
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+			}
+			Tidy.SortDir = dir
+			Tidy.OnClash = test.policy
+			fsys := afero.NewBasePathFs(Tidy.Fs, dir)
+			for _, name := range []string{"Images", "cat.jpg"} {
+				if err := afero.WriteFile(fsys, name, []byte(name), 0o644); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+				}
+			}
+
+			for name, want := range map[string]string{"cat.jpg": test.cat, "Images": test.images} {
+				e, err := Tidy.Explain(name)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to explain %s: %v", failed, testID, name, err)
+				}
+				if want != "" {
+					want = filepath.Join(dir, want)
+				}
+				if e.Destination != want {
+					t.Fatalf("\t%s\tTest %d:\tShould explain %s as moved to %q, got %q:\n%s", failed, testID, name, want, e.Destination, e)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould explain where the files go once the clash is resolved.", success, testID)
+
+			if _, err := fsys.Stat("Images"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould leave the file in the way alone: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not change anything.", success, testID)
+		}
+	}
+}
+
+func TestStatusClash(t *testing.T) {
+	t.Log("Given the need to check the status of a directory with a file in the way of a sorting folder.")
+
+	testID := 0
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	// fsys is the SortDir, where the files of the test live.
+	fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+	if err := afero.WriteFile(fsys, "Images", []byte("Images"), 0o644); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+	}
+
+	t.Logf("\tTest %d:\tWhen checking the directory.", testID)
+	{
+		status, err := Tidy.Status()
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to check the status: %v", failed, testID, err)
+		}
+		want := FolderClash{Path: filepath.Join(Tidy.SortDir, "Images"), Category: "Images", Policy: ClashSort, Dest: filepath.Join(Tidy.SortDir, "Other", "Images")}
+		if len(status.Clashes) != 1 || status.Clashes[0].Path != want.Path || status.Clashes[0].Policy != want.Policy || status.Clashes[0].Dest != want.Dest {
+			t.Fatalf("\t%s\tTest %d:\tShould report the clash, got %+v", failed, testID, status.Clashes)
+		}
+		t.Logf("\t%s\tTest %d:\tShould report the clash.", success, testID)
+
+		for _, path := range status.MissingFolders {
+			if path == want.Path {
+				t.Fatalf("\t%s\tTest %d:\tShould not report the folder as missing, got %v", failed, testID, status.MissingFolders)
+			}
+		}
+		if status.Scaffolding != ScaffoldingMissing || status.Clean() {
+			t.Fatalf("\t%s\tTest %d:\tShould report the scaffolding as missing, got %s", failed, testID, status.Scaffolding)
+		}
+		t.Logf("\t%s\tTest %d:\tShould not report the folder as missing.", success, testID)
+	}
+}
@@ -0,0 +1,460 @@
+package tidy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the user configuration of tidy, which is read from a YAML file.
+// Every field is optional: an empty Config leaves the defaults of NewTidy and
+// NewFiletypeSorter untouched.
+//
+// An example configuration which routes images and documents out of the sorted
+// directory:
+//
+//	dest: ~/Sorted
+//	template: "{category}/{year}/{month}/{name}"
+//	rename: [collapse-whitespace, strip-copy-suffix, lowercase-ext]
+//	on_conflict: rename
+//	rules:
+//	  - name: invoices
+//	    when: ext in [pdf] and size > 10KB and name ~ "(?i)invoice"
+//	    to: Finance/Invoices
+//	  - 'age > 365d -> Archive'
+//	categories:
+//	  Images:
+//	    dest: ~/Pictures/Inbox
+//	  Documents:
+//	    dest: ~/Documents/Inbox
+//	    rename: [slugify, date-prefix]
+type Config struct {
+	// Dest is the root directory in which the sorting folders are created. When
+	// empty, files are sorted in place inside of the SortDir. A relative Dest is
+	// relative to the SortDir, like the dest of a category.
+	Dest string `yaml:"dest"`
+
+	// Template describes where files are placed inside of Dest, see PathTemplate.
+	Template string `yaml:"template"`
+
+	// Rename lists the rename rules applied to every file as it is sorted, see
+	// RenameRule for the available rules.
+	Rename []string `yaml:"rename"`
+
+	// OnConflict is the name of the ConflictPolicy used when a file already
+	// exists at the destination: skip, rename or overwrite.
+	OnConflict string `yaml:"on_conflict"`
+
+	// OnClash is the name of the ClashPolicy used when a file is in the way of
+	// a sorting folder: sort, rename or rename-folder.
+	OnClash string `yaml:"on_clash"`
+
+	// FailFast stops a sort at the first file it cannot move, instead of moving
+	// every other file and reporting the failures at the end.
+	FailFast bool `yaml:"fail_fast"`
+
+	// Protected lists more directories which are never sorted, on top of the
+	// built-in ones such as the home directory, see Tidy.Force.
+	Protected []string `yaml:"protected"`
+
+	// LockWait is how long a sort waits for another sort of the same directory
+	// to finish, such as "30s", see Tidy.LockWait.
+	LockWait time.Duration `yaml:"lock_wait"`
+
+	// Rules route files matching a condition into a folder, ahead of the lookup of
+	// the Sorter. The first rule that matches a file wins, see Rule.
+	Rules []RuleConfig `yaml:"rules"`
+
+	// Categories holds the settings of individual sorting folders, keyed by the
+	// name of the folder.
+	Categories map[string]CategoryConfig `yaml:"categories"`
+
+	// Hooks are commands run as files are sorted, see HooksConfig.
+	Hooks HooksConfig `yaml:"hooks"`
+
+	// Webhooks receive the activity of every sort, see WebhookSink.
+	Webhooks []WebhookConfig `yaml:"webhooks"`
+
+	// Jobs lists the directories that tidy daemon sorts, and when.
+	Jobs []JobConfig `yaml:"jobs"`
+}
+
+// JobConfig describes a directory that is sorted on a schedule by tidy daemon.
+// A job uses the rest of the config, with its own Dest and Template taking
+// precedence, for example to archive the desktop every night:
+//
+//	jobs:
+//	  - name: downloads
+//	    dir: ~/Downloads
+//	    schedule: "@hourly"
+//	  - name: desktop-archive
+//	    dir: ~/Desktop
+//	    schedule: "0 2 * * *"
+//	    dest: ~/Archive
+//	    template: "{year}/{month}/{name}"
+//	  - name: screenshots
+//	    dir: ~/Pictures/Screenshots
+//	    watch: true
+type JobConfig struct {
+	// Name identifies the job in logs and reports. Defaults to Dir.
+	Name string `yaml:"name" json:"name"`
+
+	// Dir is the directory the job sorts.
+	Dir string `yaml:"dir" json:"dir"`
+
+	// Schedule is a cron expression, such as "0 * * * *", or one of the
+	// descriptors @hourly, @daily, @weekly, @monthly or "@every <duration>".
+	// May be empty for jobs which Watch their Dir.
+	Schedule string `yaml:"schedule" json:"schedule,omitempty"`
+
+	// Watch sorts new files in Dir as soon as they have settled, like tidy watch,
+	// in addition to the Schedule.
+	Watch bool `yaml:"watch" json:"watch,omitempty"`
+
+	Dest     string `yaml:"dest" json:"dest,omitempty"`
+	Template string `yaml:"template" json:"template,omitempty"`
+
+	// Force sorts Dir even if it is protected, see Tidy.Force.
+	Force bool `yaml:"force" json:"force,omitempty"`
+}
+
+// Apply configures t for the job, on top of the global config which should be
+// applied first. The SortDir of t is left unchanged.
+func (jc *JobConfig) Apply(t *Tidy) error {
+	if jc.Dest != "" {
+		if err := applyDest(t, jc.Dest); err != nil {
+			return err
+		}
+	}
+	if jc.Template != "" {
+		pt, err := ParsePathTemplate(jc.Template)
+		if err != nil {
+			return err
+		}
+		t.Template = pt
+	}
+	if jc.Force {
+		t.Force = true
+	}
+	return nil
+}
+
+// CategoryConfig holds the settings of a single sorting folder.
+type CategoryConfig struct {
+	// Dest is the directory in which files of this category are placed, instead
+	// of a folder named after the category in the destination root.
+	Dest string `yaml:"dest"`
+
+	// Rename replaces the global rename rules for files of this category.
+	Rename []string `yaml:"rename"`
+}
+
+// RuleConfig holds a single rule. In the config file, a rule is either written
+// as a mapping with the keys name, when and to, or on a single line in the form
+// "<condition> -> <target>".
+type RuleConfig struct {
+	Name string `yaml:"name"`
+	When string `yaml:"when"`
+	To   string `yaml:"to"`
+}
+
+// UnmarshalYAML allows a RuleConfig to be written on a single line.
+func (rc *RuleConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		when, to, err := splitRule(value.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", value.Line, err)
+		}
+		rc.When, rc.To = when, to
+		return nil
+	}
+	type plain RuleConfig
+	return value.Decode((*plain)(rc))
+}
+
+// HooksConfig holds the hooks of every HookEvent, see Hook for the values a
+// command can use. Each hook is either a mapping with the keys category and
+// run, or written on a single line as a command, optionally prefixed with a
+// category in the form "<category> -> <command>". An event with a single hook
+// does not need a list:
+//
+//	hooks:
+//	  before_move:
+//	    - category: Compressed
+//	      run: unzip -tq {src}
+//	  on_move:
+//	    - Images -> "exiftool -overwrite_original -all= {dest}"
+//	  after_run: notify-send "tidy moved $TIDY_MOVED files"
+type HooksConfig struct {
+	BeforeMove []HookConfig `yaml:"before_move"`
+	OnMove     []HookConfig `yaml:"on_move"`
+	AfterRun   []HookConfig `yaml:"after_run"`
+}
+
+// UnmarshalYAML allows an event with a single hook to be written without a list.
+func (hc *HooksConfig) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		BeforeMove yaml.Node `yaml:"before_move"`
+		OnMove     yaml.Node `yaml:"on_move"`
+		AfterRun   yaml.Node `yaml:"after_run"`
+	}
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	for _, v := range []struct {
+		node  *yaml.Node
+		hooks *[]HookConfig
+	}{
+		{&raw.BeforeMove, &hc.BeforeMove},
+		{&raw.OnMove, &hc.OnMove},
+		{&raw.AfterRun, &hc.AfterRun},
+	} {
+		switch v.node.Kind {
+		case 0:
+		case yaml.SequenceNode:
+			if err := v.node.Decode(v.hooks); err != nil {
+				return err
+			}
+		default:
+			var h HookConfig
+			if err := v.node.Decode(&h); err != nil {
+				return err
+			}
+			*v.hooks = []HookConfig{h}
+		}
+	}
+	return nil
+}
+
+// HookConfig holds a single hook.
+type HookConfig struct {
+	Category string `yaml:"category"`
+	Run      string `yaml:"run"`
+}
+
+// UnmarshalYAML allows a HookConfig to be written on a single line.
+func (hc *HookConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		hc.Category, hc.Run = splitHook(value.Value)
+		return nil
+	}
+	type plain HookConfig
+	return value.Decode((*plain)(hc))
+}
+
+// hooks compiles the hooks of every event, in the order of the events.
+func (hc *HooksConfig) hooks() ([]*Hook, error) {
+	var hooks []*Hook
+	for _, v := range []struct {
+		event HookEvent
+		hooks []HookConfig
+	}{
+		{HookBeforeMove, hc.BeforeMove},
+		{HookOnMove, hc.OnMove},
+		{HookAfterRun, hc.AfterRun},
+	} {
+		for _, h := range v.hooks {
+			hook, err := NewHook(v.event, h.Category, h.Run)
+			if err != nil {
+				return nil, err
+			}
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks, nil
+}
+
+// WebhookConfig describes a webhook that receives the events of every sort, for
+// example:
+//
+//	webhooks:
+//	  - url: https://example.com/tidy
+//	    headers:
+//	      Authorization: Bearer secret
+//	    batch_size: 50
+//	    flush_interval: 5s
+type WebhookConfig struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+
+	// BatchSize is the most events sent in a single request.
+	BatchSize int `yaml:"batch_size"`
+
+	// FlushInterval is the longest an event waits before it is sent.
+	FlushInterval time.Duration `yaml:"flush_interval"`
+
+	// Retries is how many times a failed request is retried. 0 retries the
+	// default of 3 times, so only a negative value disables retries.
+	// RetryBackoff is how long to wait before the first retry.
+	Retries      int           `yaml:"retries"`
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+}
+
+// DefaultConfigPath returns the location of the configuration file used when no
+// other path is provided: tidy/config.yaml inside of the user config directory.
+func DefaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tidy", "config.yaml"), nil
+}
+
+// applyDest sets the DestDir of t to a dest from the config. Unlike
+// Tidy.ChangeDestDir, a relative dest is left relative to the SortDir, the same
+// base as the dest of a category, see run.abs.
+func applyDest(t *Tidy, dest string) error {
+	dest, err := expandHome(dest)
+	if err != nil {
+		return err
+	}
+	if filepath.IsAbs(dest) {
+		return t.ChangeDestDir(dest)
+	}
+	t.DestDir = filepath.Clean(dest)
+	return nil
+}
+
+// LoadConfig reads and parses the configuration file at path.
+func LoadConfig(fsys afero.Fs, path string) (*Config, error) {
+	b, err := afero.ReadFile(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Apply configures t according to the Config. Paths in the Config may start
+// with "~" to refer to the home directory of the user, relative paths are
+// relative to the SortDir.
+func (c *Config) Apply(t *Tidy) error {
+	if c.Dest != "" {
+		if err := applyDest(t, c.Dest); err != nil {
+			return err
+		}
+	}
+
+	if c.Template != "" {
+		pt, err := ParsePathTemplate(c.Template)
+		if err != nil {
+			return err
+		}
+		t.Template = pt
+	}
+
+	if c.Rename != nil {
+		rules, err := ParseRenameRules(c.Rename)
+		if err != nil {
+			return err
+		}
+		t.RenameRules = rules
+	}
+
+	if c.OnConflict != "" {
+		policy, err := ParseConflictPolicy(c.OnConflict)
+		if err != nil {
+			return err
+		}
+		t.OnConflict = policy
+	}
+
+	if c.OnClash != "" {
+		policy, err := ParseClashPolicy(c.OnClash)
+		if err != nil {
+			return err
+		}
+		t.OnClash = policy
+	}
+
+	if c.FailFast {
+		t.FailFast = true
+	}
+
+	for _, p := range c.Protected {
+		p, err := expandHome(p)
+		if err != nil {
+			return err
+		}
+		t.ProtectedPaths = append(t.ProtectedPaths, absPath(p))
+	}
+
+	if c.LockWait != 0 {
+		t.LockWait = c.LockWait
+	}
+
+	if len(c.Rules) > 0 {
+		rules := make([]*Rule, 0, len(c.Rules))
+		for _, rc := range c.Rules {
+			rule, err := CompileRule(rc.Name, rc.When, rc.To)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, rule)
+		}
+		t.Rules = rules
+	}
+
+	hooks, err := c.Hooks.hooks()
+	if err != nil {
+		return err
+	}
+	if len(hooks) > 0 {
+		t.Hooks = hooks
+	}
+
+	if err := c.applyCategories(t); err != nil {
+		return err
+	}
+
+	// webhooks are started last, so that an invalid config does not leave them
+	// running in the background.
+	for _, wc := range c.Webhooks {
+		sink, err := NewWebhookSink(wc)
+		if err != nil {
+			return err
+		}
+		t.Sinks = append(t.Sinks, sink)
+	}
+	return nil
+}
+
+// applyCategories configures the folders of the sorter of t.
+func (c *Config) applyCategories(t *Tidy) error {
+	if len(c.Categories) == 0 {
+		return nil
+	}
+	fts, ok := t.Sorter.(*FiletypeSorter)
+	if !ok {
+		return fmt.Errorf("categories can only be configured for the filetype sorter")
+	}
+	for name, cc := range c.Categories {
+		folder := fts.folder(name)
+		if folder == nil {
+			return fmt.Errorf("unknown category %q in config", name)
+		}
+		if cc.Dest != "" {
+			dest, err := expandHome(cc.Dest)
+			if err != nil {
+				return err
+			}
+			// a relative dest is left relative to the SortDir, see run.folderPath.
+			folder.Dest = dest
+		}
+		if cc.Rename != nil {
+			rules, err := ParseRenameRules(cc.Rename)
+			if err != nil {
+				return err
+			}
+			folder.Rename = rules
+		}
+	}
+	return nil
+}
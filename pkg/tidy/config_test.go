@@ -0,0 +1,99 @@
+package tidy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestSortWithCategoryDest(t *testing.T) {
+	t.Log("Given the need to sort a category into a destination of its own, given relative to the SortDir.")
+
+	testID := 0
+	config := `
+categories:
+  Images:
+    dest: Inbox/Pictures
+`
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	// the SortDir is not the working directory, like it is for the daemon.
+	Tidy.SortDir = filepath.Join(os.TempDir(), "sorted")
+	// fsys is the SortDir, where the files of the test live.
+	fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+	if err := afero.WriteFile(fsys, "config.yaml", []byte(config), 0o644); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to write the config file: %v", failed, testID, err)
+	}
+	cfg, err := LoadConfig(fsys, "config.yaml")
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to load the config: %v", failed, testID, err)
+	}
+	if err := fsys.Remove("config.yaml"); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to remove the config file: %v", failed, testID, err)
+	}
+	if err := cfg.Apply(Tidy); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to apply the config: %v", failed, testID, err)
+	}
+	if err := afero.WriteFile(fsys, "cat.jpg", []byte("meow"), 0o644); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+	}
+
+	t.Logf("\tTest %d:\tWhen sorting the directory.", testID)
+	{
+		if _, err := Tidy.Sort(); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to call Tidy.Sort() without error: %v", failed, testID, err)
+		}
+		if _, err := fsys.Stat("Inbox/Pictures/cat.jpg"); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould have sorted the file into the destination inside the SortDir: %v", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould have sorted the file into the destination inside the SortDir.", success, testID)
+	}
+}
+
+func TestSortWithRelativeDest(t *testing.T) {
+	t.Log("Given the need to sort into a destination root given in the config, relative to the SortDir.")
+
+	testID := 0
+	config := `
+dest: Sorted
+`
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	// the SortDir is not the working directory, like it is for the daemon.
+	Tidy.SortDir = filepath.Join(os.TempDir(), "sorted")
+	// fsys is the SortDir, where the files of the test live.
+	fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+	if err := afero.WriteFile(fsys, "config.yaml", []byte(config), 0o644); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to write the config file: %v", failed, testID, err)
+	}
+	cfg, err := LoadConfig(fsys, "config.yaml")
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to load the config: %v", failed, testID, err)
+	}
+	if err := fsys.Remove("config.yaml"); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to remove the config file: %v", failed, testID, err)
+	}
+	if err := cfg.Apply(Tidy); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to apply the config: %v", failed, testID, err)
+	}
+	if err := afero.WriteFile(fsys, "cat.jpg", []byte("meow"), 0o644); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+	}
+
+	t.Logf("\tTest %d:\tWhen sorting the directory.", testID)
+	{
+		if _, err := Tidy.Sort(); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to call Tidy.Sort() without error: %v", failed, testID, err)
+		}
+		if _, err := fsys.Stat("Sorted/Images/cat.jpg"); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould have sorted the file into the destination inside the SortDir: %v", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould have sorted the file into the destination inside the SortDir.", success, testID)
+	}
+}
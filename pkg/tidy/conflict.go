@@ -0,0 +1,81 @@
+package tidy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ConflictPolicy decides what happens when a file is sorted to a destination at
+// which a file already exists.
+type ConflictPolicy string
+
+const (
+	// ConflictSkip leaves the file where it is. This is the default.
+	ConflictSkip ConflictPolicy = "skip"
+
+	// ConflictRename sorts the file under a free name, by adding a counter to the
+	// end of its name: "report.pdf" becomes "report-2.pdf".
+	ConflictRename ConflictPolicy = "rename"
+
+	// ConflictOverwrite replaces the existing file. The replaced file can not be
+	// brought back by Undo.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+)
+
+// ParseConflictPolicy converts the name of a conflict policy, as used in the
+// config and on the command line, into a ConflictPolicy.
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case ConflictSkip, ConflictRename, ConflictOverwrite:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %q, expected one of skip, rename or overwrite", s)
+}
+
+// resolveConflict checks whether something already exists at dst, and applies the
+// conflict policy of the run if it does. It returns the path the file should be
+// moved to, whether there was a conflict at all, and skip set to true if the
+// file should be left where it is.
+func (r *run) resolveConflict(dst string) (resolved string, conflict, skip bool, err error) {
+	_, err = r.fs.Stat(dst)
+	if os.IsNotExist(err) {
+		return dst, false, false, nil
+	}
+	if err != nil {
+		return "", false, false, err
+	}
+
+	switch r.onConflict {
+	case ConflictOverwrite:
+		return dst, true, false, nil
+	case ConflictRename:
+		candidate, err := r.freeName(dst, false)
+		if err != nil {
+			return "", true, false, err
+		}
+		return candidate, true, false, nil
+	default:
+		return dst, true, true, nil
+	}
+}
+
+// freeName returns the first of path-2, path-3 and so on, with the counter put
+// in front of the extension, at which nothing exists yet. With dirOK set, an
+// existing directory is taken as well.
+func (r *run) freeName(path string, dirOK bool) (string, error) {
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+	for n := 2; ; n++ {
+		candidate := stem + "-" + strconv.Itoa(n) + ext
+		info, err := r.fs.Stat(candidate)
+		if os.IsNotExist(err) || (err == nil && dirOK && info.IsDir()) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package tidy
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type SortingError struct {
 	Filename string
@@ -13,3 +16,21 @@ func (se *SortingError) Error() string {
 	return fmt.Sprintf("Sorting Error: Could not move file to desired destination.\n\tFile:\t[%s]\n\tDest:\t[%s]\n\n\tError:\t%s",
 		se.Filename, se.AbsPath, se.Err.Error())
 }
+
+func (se *SortingError) Unwrap() error {
+	return se.Err
+}
+
+// SortingErrors lists every entry a sort could not move. Unless FailFast is set,
+// a sort keeps going past the entries it cannot move, and returns all of them as
+// SortingErrors once done.
+type SortingErrors []*SortingError
+
+func (se SortingErrors) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d entries could not be sorted:", len(se))
+	for _, err := range se {
+		fmt.Fprintf(&b, "\n\n%s", err)
+	}
+	return b.String()
+}
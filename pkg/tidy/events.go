@@ -0,0 +1,125 @@
+package tidy
+
+import "time"
+
+// EventType is the kind of activity an Event reports.
+type EventType string
+
+const (
+	// EventRunStarted is sent when a sort starts.
+	EventRunStarted EventType = "run_started"
+
+	// EventMkdir is sent for every directory a sort creates, such as the
+	// scaffolding.
+	EventMkdir EventType = "mkdir"
+
+	// EventConflict is sent when something already exists at the destination of
+	// an entry. It is followed by an EventSkip or an EventMove, depending on the
+	// conflict policy.
+	EventConflict EventType = "conflict"
+
+	// EventSkip is sent for every entry that is left where it is, although it
+	// would have been moved.
+	EventSkip EventType = "skip"
+
+	// EventMove is sent for every entry that is moved into its folder.
+	EventMove EventType = "move"
+
+	// EventError is sent for every entry that could not be sorted.
+	EventError EventType = "error"
+
+	// EventRunFinished is sent when a sort is done, whether it succeeded or not.
+	EventRunFinished EventType = "run_finished"
+
+	// EventRestore is sent for every entry an undo brings back to where it was
+	// sorted from.
+	EventRestore EventType = "restore"
+
+	// EventRmdir is sent for every directory an undo removes.
+	EventRmdir EventType = "rmdir"
+
+	// EventClash is sent when a file is in the way of a sorting folder, once
+	// the clash policy was applied to it.
+	EventClash EventType = "clash"
+
+	// EventHookFailed is sent when an on_move hook fails. The entry was moved
+	// regardless.
+	EventHookFailed EventType = "hook_failed"
+)
+
+// Event describes a single piece of sort activity, as it is sent to the
+// EventSinks of a Tidy. A move carries the same data that is logged for it.
+type Event struct {
+	Type    EventType `json:"type"`
+	Run     string    `json:"run"`
+	Time    time.Time `json:"time"`
+	SortDir string    `json:"sort_dir"`
+
+	// File is the name of the entry that was moved or could not be sorted.
+	File string `json:"file,omitempty"`
+
+	// Dest is the absolute path the entry was moved to, or would have been moved
+	// to. For EventMkdir and EventRmdir, it is the directory that was created or
+	// removed.
+	Dest           string `json:"dest,omitempty"`
+	IsDir          bool   `json:"is_dir,omitempty"`
+	KnownExtension bool   `json:"known_extension,omitempty"`
+
+	// Category is the folder a moved entry was sorted into, and Size its size in
+	// bytes, which is 0 for directories.
+	Category string `json:"category,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+
+	// Policy is the conflict policy applied to an EventConflict.
+	Policy ConflictPolicy `json:"policy,omitempty"`
+
+	// Clash is the clash policy applied to an EventClash. Category is the
+	// sorting folder the file was in the way of, and Dest is where the file was
+	// moved, or the folder which is used instead.
+	Clash ClashPolicy `json:"clash,omitempty"`
+
+	// Reason explains why an entry was skipped.
+	Reason string `json:"reason,omitempty"`
+
+	// Moved is the number of entries a finished run moved.
+	Moved int `json:"moved,omitempty"`
+
+	Error string `json:"error,omitempty"`
+
+	// err is the error behind Error, which is handed to Observers.
+	err error
+}
+
+// EventSink receives the events of every sort and undo. Send is called
+// synchronously while sorting, so sinks which do slow work, such as talking to a
+// server, should hand events off to be sent in the background. Sinks which
+// implement io.Closer are closed by Tidy.Close.
+type EventSink interface {
+	Send(e Event)
+}
+
+// emit counts e towards the Result of the run, and sends it to every sink of
+// the run, filling in the fields every event shares. Events are sent one at a
+// time, even while the moves of a sort run in parallel.
+func (r *run) emit(e Event) {
+	r.emitMu.Lock()
+	defer r.emitMu.Unlock()
+	r.result.add(e)
+	if len(r.sinks) == 0 {
+		return
+	}
+	if e.err != nil {
+		e.Error = e.err.Error()
+	}
+	e.Run, e.Time, e.SortDir = r.id, time.Now(), r.sortDir
+	for _, s := range r.sinks {
+		s.Send(e)
+	}
+}
+
+// finish completes the Result of the run and sends the EventRunFinished event,
+// err is the error the run failed with, if any.
+func (r *run) finish(err error) {
+	r.result.finish(err)
+	r.emit(Event{Type: EventRunFinished, Moved: r.result.Moved, Error: r.result.Error, err: err})
+}
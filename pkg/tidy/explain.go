@@ -0,0 +1,183 @@
+package tidy
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// classification is the decision a Sorter makes about a single entry of the
+// SortDir, along with the reasoning that led to it.
+type classification struct {
+	// folder is the folder the entry belongs in. A nil folder means the entry is
+	// left where it is.
+	folder *FiletypeSortingFolder
+
+	// rule is the rule that matched the entry, if any.
+	rule *Rule
+
+	isDir          bool
+	knownExtension bool
+
+	// steps are only noted down when explain is set, as formatting them for
+	// every entry of a sort would be wasted.
+	explain bool
+	steps   []string
+}
+
+func (c *classification) note(format string, args ...interface{}) {
+	if !c.explain {
+		return
+	}
+	c.steps = append(c.steps, fmt.Sprintf(format, args...))
+}
+
+// Explanation describes where Sort would move a file, and why.
+type Explanation struct {
+	// Path is the absolute path of the explained file.
+	Path string
+
+	// Steps lists the decisions that were made about the file, in order.
+	Steps []string
+
+	// Category is the name of the folder the file belongs in, and Rule is the
+	// rule that put it there, if any. Both are empty if the file would be left
+	// alone.
+	Category string
+	Rule     string
+
+	// Destination is the absolute path the file would be moved to, taking
+	// conflicts into account. It is empty if the file would not be moved.
+	Destination string
+
+	// Conflict describes what is already at the destination of the file, if
+	// anything, and what the conflict policy does about it.
+	Conflict string
+}
+
+// Moves reports whether Sort would move the explained file.
+func (e *Explanation) Moves() bool {
+	return e.Destination != ""
+}
+
+func (e *Explanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", e.Path)
+	for i, step := range e.Steps {
+		fmt.Fprintf(&b, "  %d. %s\n", i+1, step)
+	}
+	if e.Moves() {
+		fmt.Fprintf(&b, "=> %s", e.Destination)
+	} else {
+		fmt.Fprintf(&b, "=> not moved")
+	}
+	return b.String()
+}
+
+// Explain describes where a call to Sort would move the file at path, and how
+// that destination was chosen: the rules and lookups that were consulted, the
+// rename rules and path template that were applied, and what the conflict policy
+// does if the destination is taken. A relative path is relative to the SortDir.
+// Files in the way of a sorting folder are explained as the clash policy
+// handles them, see ClashPolicy.
+//
+// Explain does not change anything on the filesystem.
+func (t *Tidy) Explain(path string) (*Explanation, error) {
+	r := t.newRun()
+	r.explain = true
+	path = r.abs(path)
+	info, err := t.Fs.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	e := &Explanation{Path: path}
+
+	clashes, err := t.Sorter.clashes(r)
+	if err != nil {
+		return nil, err
+	}
+	clashed := make(map[string]FolderClash)
+	for _, fc := range clashes {
+		if fc.Path == path {
+			return explainClash(e, fc), nil
+		}
+		if fc.Policy != ClashRenameFolder {
+			clashed[fc.Category] = fc
+		}
+	}
+
+	c, err := t.Sorter.classify(r, path, info)
+	if err != nil {
+		return nil, err
+	}
+	e.Steps = c.steps
+	if c.folder == nil {
+		return e, nil
+	}
+	e.Category = c.folder.Name
+	if c.rule != nil {
+		e.Rule = c.rule.String()
+	}
+	if alt, ok := r.folderAlt[c.folder.Name]; ok {
+		e.Steps = append(e.Steps, fmt.Sprintf("a file is in the way of the %s folder, so %s is used instead", c.folder.Name, alt))
+	}
+
+	if name := r.targetName(c.folder, info); name != info.Name() {
+		e.Steps = append(e.Steps, fmt.Sprintf("the rename rules rename %s to %s", info.Name(), name))
+	}
+	dest, err := r.destination(c.folder, info)
+	if err != nil {
+		return nil, err
+	}
+	if c.folder.Dest != "" {
+		e.Steps = append(e.Steps, fmt.Sprintf("the %s folder has its own destination %s, where the path template %q gives %s", c.folder.Name, c.folder.Dest, r.template, dest))
+	} else {
+		e.Steps = append(e.Steps, fmt.Sprintf("the path template %q gives %s", r.template, dest))
+	}
+
+	if fc, ok := clashed[c.folder.Name]; ok {
+		// the folder is created once the file in its way is moved, so nothing
+		// can be in the way of dest.
+		e.Steps = append(e.Steps, fmt.Sprintf("%s is in the way of the %s folder, which is created once the clash policy %s moved it to %s", fc.Path, fc.Category, fc.Policy, fc.Dest))
+		e.Destination = dest
+		return e, nil
+	}
+	resolved, conflict, skip, err := r.resolveConflict(dest)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !conflict:
+	case skip:
+		e.Conflict = fmt.Sprintf("%s already exists, the file is skipped", dest)
+	case resolved != dest:
+		e.Conflict = fmt.Sprintf("%s already exists, the file is renamed to %s", dest, resolved)
+	default:
+		e.Conflict = fmt.Sprintf("%s already exists and is overwritten", dest)
+	}
+	if e.Conflict != "" {
+		e.Steps = append(e.Steps, e.Conflict)
+	}
+	if !skip {
+		e.Destination = resolved
+	}
+	return e, nil
+}
+
+// explainClash explains the file fc describes, which is in the way of a sorting
+// folder, by what the clash policy does with it.
+func explainClash(e *Explanation, fc FolderClash) *Explanation {
+	name := filepath.Base(fc.Path)
+	switch fc.Policy {
+	case ClashRenameFolder:
+		e.Steps = append(e.Steps, fmt.Sprintf("%s is in the way of the %s folder, the clash policy %s leaves it where it is and uses %s instead", name, fc.Category, fc.Policy, fc.Dest))
+	case ClashRename:
+		e.Steps = append(e.Steps, fmt.Sprintf("%s is in the way of the %s folder, the clash policy %s renames it to %s", name, fc.Category, fc.Policy, fc.Dest))
+		e.Destination = fc.Dest
+	default:
+		e.Steps = append(e.Steps, fmt.Sprintf("%s is in the way of the %s folder, the clash policy %s sorts it into %s before the folder is created", name, fc.Category, fc.Policy, fc.into.Name))
+		e.Category = fc.into.Name
+		e.Destination = fc.Dest
+	}
+	return e
+}
@@ -0,0 +1,105 @@
+package tidy
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+type explainScenario struct {
+	testID   int
+	file     string
+	policy   ConflictPolicy
+	category string
+	rule     bool
+	dest     string
+	conflict bool
+}
+
+func TestExplain(t *testing.T) {
+	t.Log("Given the need to explain where a file would be sorted, without moving it.")
+
+	tests := map[string]explainScenario{
+		"File routed by its extension.": {
+			testID:   0,
+			file:     "story.txt",
+			policy:   ConflictSkip,
+			category: "Documents",
+			dest:     "Documents/story.txt",
+		},
+		"File routed by a rule, destination taken.": {
+			testID:   1,
+			file:     "invoice.pdf",
+			policy:   ConflictRename,
+			category: "Finance/Invoices",
+			rule:     true,
+			dest:     "Finance/Invoices/invoice-2.pdf",
+			conflict: true,
+		},
+		"Destination taken and skipped.": {
+			testID:   2,
+			file:     "invoice.pdf",
+			policy:   ConflictSkip,
+			category: "Finance/Invoices",
+			rule:     true,
+			dest:     "",
+			conflict: true,
+		},
+		"Sorting folder left alone.": {
+			testID: 3,
+			file:   "Images",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(strconv.Itoa(tc.testID), func(t *testing.T) {
+			t.Logf("\tTest %d:\t%s", tc.testID, name)
+
+			Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, tc.testID, err)
+			}
+			// fsys is the SortDir, where the files of the test live.
+			fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+			rule, err := ParseRule(`name glob "invoice*" -> Finance/Invoices`)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to compile the rule: %v", failed, tc.testID, err)
+			}
+			Tidy.Rules = []*Rule{rule}
+			Tidy.OnConflict = tc.policy
+
+			for _, v := range []string{"story.txt", "invoice.pdf", "Finance/Invoices/invoice.pdf"} {
+				if err := afero.WriteFile(fsys, v, nil, 0o644); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, tc.testID, err)
+				}
+			}
+			if err := fsys.Mkdir("Images", 0o755); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of directories in the test filesystem: %v", failed, tc.testID, err)
+			}
+
+			e, err := Tidy.Explain(tc.file)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to call Tidy.Explain() without error: %v", failed, tc.testID, err)
+			}
+			t.Logf("\t\tTest %d:\t%s", tc.testID, e)
+
+			if e.Category != tc.category || (e.Rule != "") != tc.rule || (e.Conflict != "") != tc.conflict {
+				t.Fatalf("\t%s\tTest %d:\tShould have explained the category %q, rule %v and conflict %v.", failed, tc.testID, tc.category, tc.rule, tc.conflict)
+			}
+			want := ""
+			if tc.dest != "" {
+				want = absPath(filepath.FromSlash(tc.dest))
+			}
+			if e.Destination != want {
+				t.Fatalf("\t%s\tTest %d:\tShould have explained the destination %q, got %q.", failed, tc.testID, want, e.Destination)
+			}
+			if _, err := fsys.Stat(tc.file); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not have moved the file: %v", failed, tc.testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have explained the destination without moving the file.", success, tc.testID)
+		})
+	}
+}
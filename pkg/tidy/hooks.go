@@ -0,0 +1,156 @@
+package tidy
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// HookEvent is the moment at which a Hook runs.
+type HookEvent string
+
+const (
+	// HookBeforeMove runs before a file is moved. A hook which fails vetoes the
+	// move, and the file is left where it is.
+	HookBeforeMove HookEvent = "before_move"
+
+	// HookOnMove runs after a file has been moved.
+	HookOnMove HookEvent = "on_move"
+
+	// HookAfterRun runs once a sort has finished successfully, even if it did
+	// not move anything. A Watcher sorts every file in a run of its own.
+	HookAfterRun HookEvent = "after_run"
+)
+
+// Hook is a command run by the shell when files are sorted. The command may
+// refer to the file with the placeholders {src}, {dest}, {name}, {category} and
+// {sort_dir}, which are replaced by their shell quoted values. The same values
+// are passed in the environment variables TIDY_SRC, TIDY_DEST, TIDY_NAME,
+// TIDY_CATEGORY and TIDY_SORT_DIR, along with TIDY_EVENT. After a run, TIDY_MOVED
+// holds the number of files that were moved.
+//
+// Hooks run in the SortDir, and everything they print is logged.
+type Hook struct {
+	Event HookEvent
+
+	// Category limits the hook to files sorted into the folder of that name. An
+	// empty Category matches every file. It has no effect on HookAfterRun.
+	Category string
+
+	Command string
+}
+
+// hookVars are the values a Hook can refer to.
+type hookVars struct {
+	src, dest, name, category, sortDir string
+	moved                              int
+}
+
+// NewHook returns a Hook for event. The category is optional.
+func NewHook(event HookEvent, category, command string) (*Hook, error) {
+	switch event {
+	case HookBeforeMove, HookOnMove, HookAfterRun:
+	default:
+		return nil, fmt.Errorf("unknown hook event %q", event)
+	}
+	if strings.TrimSpace(command) == "" {
+		return nil, fmt.Errorf("%s hook: empty command", event)
+	}
+	return &Hook{Event: event, Category: strings.TrimSpace(category), Command: command}, nil
+}
+
+// ParseHook parses a hook written on a single line, either as a bare command or
+// in the form "<category> -> <command>".
+func ParseHook(event HookEvent, s string) (*Hook, error) {
+	category, command := splitHook(s)
+	return NewHook(event, category, command)
+}
+
+// splitHook splits "<category> -> <command>" into its parts. The arrow of a
+// command which merely contains one, such as "echo a -> b", is not mistaken for
+// a category, since categories are a single word.
+func splitHook(s string) (category, command string) {
+	i := strings.Index(s, "->")
+	if i == -1 {
+		return "", strings.TrimSpace(s)
+	}
+	category = strings.TrimSpace(s[:i])
+	if category == "" || strings.ContainsAny(category, " \t") {
+		return "", strings.TrimSpace(s)
+	}
+	command = strings.TrimSpace(s[i+2:])
+	if unquoted, err := strconv.Unquote(command); err == nil {
+		command = unquoted
+	}
+	return category, command
+}
+
+func (h *Hook) String() string {
+	if h.Category != "" {
+		return fmt.Sprintf("%s: %s -> %s", h.Event, h.Category, h.Command)
+	}
+	return fmt.Sprintf("%s: %s", h.Event, h.Command)
+}
+
+// matches reports whether the hook runs for event, for a file of category.
+func (h *Hook) matches(event HookEvent, category string) bool {
+	return h.Event == event && (h.Category == "" || event == HookAfterRun || h.Category == category)
+}
+
+// command returns the command of the hook with its placeholders replaced.
+func (h *Hook) command(vars hookVars) string {
+	return strings.NewReplacer(
+		"{src}", shellQuote(vars.src),
+		"{dest}", shellQuote(vars.dest),
+		"{name}", shellQuote(vars.name),
+		"{category}", shellQuote(vars.category),
+		"{sort_dir}", shellQuote(vars.sortDir),
+	).Replace(h.Command)
+}
+
+// runHooks runs the hooks of the run for event, in the order they were
+// configured. Every hook runs even if an earlier one failed, the first error is
+// returned. Hooks which are still running once the run is cancelled are killed.
+func (r *run) runHooks(event HookEvent, vars hookVars) error {
+	var first error
+	for _, h := range r.hooks {
+		if !h.matches(event, vars.category) {
+			continue
+		}
+		cmd := exec.CommandContext(r.ctx, "sh", "-c", h.command(vars))
+		cmd.Dir = vars.sortDir
+		cmd.Env = append(os.Environ(),
+			"TIDY_EVENT="+string(event),
+			"TIDY_SRC="+vars.src,
+			"TIDY_DEST="+vars.dest,
+			"TIDY_NAME="+vars.name,
+			"TIDY_CATEGORY="+vars.category,
+			"TIDY_SORT_DIR="+vars.sortDir,
+			"TIDY_MOVED="+strconv.Itoa(vars.moved),
+		)
+		out, err := cmd.CombinedOutput()
+		output := strings.TrimSpace(string(out))
+		if err != nil {
+			r.logger.Error().Err(err).Str("Hook", h.String()).Str("File", vars.name).Str("Output", output).Msg("Hook failed.")
+			if first == nil {
+				first = fmt.Errorf("hook %q: %w", h.Command, err)
+			}
+			continue
+		}
+		r.logger.Info().Str("Hook", h.String()).Str("File", vars.name).Str("Output", output).Msg("Ran hook.")
+	}
+	return first
+}
+
+// afterRun runs the HookAfterRun hooks once a sort has finished. Since the sort
+// itself is done, a failing hook is only logged.
+func (r *run) afterRun() {
+	r.runHooks(HookAfterRun, hookVars{sortDir: r.sortDir, moved: r.result.Moved})
+}
+
+// shellQuote quotes s so that the shell passes it on as a single argument.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
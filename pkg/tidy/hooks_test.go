@@ -0,0 +1,97 @@
+package tidy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSortWithHooks(t *testing.T) {
+	t.Log("Given the need to run user commands as files are sorted.")
+
+	testID := 0
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create a temporary directory: %v", failed, testID, err)
+	}
+	out := t.TempDir()
+	for _, v := range []string{"beach.jpg", "story.txt", "backup.zip"} {
+		if err := os.WriteFile(filepath.Join(dir, v), []byte("tidy"), 0o644); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to create %s: %v", failed, testID, v, err)
+		}
+	}
+
+	cfg := &Config{}
+	doc := `
+hooks:
+  before_move: Compressed -> "test {name} != backup.zip"
+  on_move:
+    - Images -> "echo {category} {name} >> ` + filepath.Join(out, "moved") + `"
+    - Documents -> "false"
+  after_run:
+    - run: echo $TIDY_MOVED > ` + filepath.Join(out, "count") + `
+`
+	if err := yaml.Unmarshal([]byte(doc), cfg); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to parse the hooks: %v", failed, testID, err)
+	}
+
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewOsFs())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	if err := cfg.Apply(Tidy); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to apply the config: %v", failed, testID, err)
+	}
+	if len(Tidy.Hooks) != 4 {
+		t.Fatalf("\t%s\tTest %d:\tShould have configured 4 hooks, got %v", failed, testID, Tidy.Hooks)
+	}
+	var hookFailed []Event
+	Tidy.Sinks = append(Tidy.Sinks, sinkFunc(func(e Event) {
+		if e.Type == EventHookFailed {
+			hookFailed = append(hookFailed, e)
+		}
+	}))
+	if err := Tidy.ChangeSortDir(dir); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to change the sort directory: %v", failed, testID, err)
+	}
+
+	t.Logf("\tTest %d:\tWhen sorting with hooks configured.", testID)
+	{
+		if _, err := Tidy.Sort(); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to sort: %v", failed, testID, err)
+		}
+
+		for _, v := range []string{"Images/beach.jpg", "Documents/story.txt", "backup.zip"} {
+			if _, err := os.Stat(filepath.Join(dir, v)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould have found %s: %v", failed, testID, v, err)
+			}
+		}
+		t.Logf("\t%s\tTest %d:\tShould leave the file whose before_move hook failed in place.", success, testID)
+
+		moved, err := os.ReadFile(filepath.Join(out, "moved"))
+		if err != nil || strings.TrimSpace(string(moved)) != "Images beach.jpg" {
+			t.Fatalf("\t%s\tTest %d:\tShould have run the on_move hook for Images only, got %q: %v", failed, testID, moved, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould run on_move hooks for the files of their category.", success, testID)
+
+		if len(hookFailed) != 1 || hookFailed[0].File != "story.txt" || hookFailed[0].Error == "" {
+			t.Fatalf("\t%s\tTest %d:\tShould report the on_move hook which failed, got %+v", failed, testID, hookFailed)
+		}
+		t.Logf("\t%s\tTest %d:\tShould report the on_move hook which failed.", success, testID)
+
+		count, err := os.ReadFile(filepath.Join(out, "count"))
+		if err != nil || strings.TrimSpace(string(count)) != "2" {
+			t.Fatalf("\t%s\tTest %d:\tShould have passed the number of moved files to the after_run hook, got %q: %v", failed, testID, count, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould run after_run hooks once the sort is done.", success, testID)
+	}
+}
+
+// sinkFunc is an EventSink which calls itself with every event.
+type sinkFunc func(e Event)
+
+func (f sinkFunc) Send(e Event) { f(e) }
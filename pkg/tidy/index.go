@@ -0,0 +1,180 @@
+package tidy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+const indexFileName = "index"
+
+// indexEntry is what the index remembers about an entry of the SortDir which a
+// sort left where it was.
+type indexEntry struct {
+	Name    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Inode   uint64    `json:"inode,omitempty"`
+
+	// Category is the folder the entry would have been sorted into, it is empty
+	// for entries which are never sorted, such as the category folders.
+	Category string `json:"category,omitempty"`
+
+	// Dest is the destination which was in the way of an entry that was skipped
+	// for a conflict, relative to the SortDir if it lies inside of it. The entry
+	// is looked at again once Dest is gone.
+	Dest string `json:"dest,omitempty"`
+}
+
+func newIndexEntry(info fs.FileInfo, category string) indexEntry {
+	return indexEntry{Name: info.Name(), Size: info.Size(), ModTime: info.ModTime(), Inode: fileInode(info), Category: category}
+}
+
+// index remembers the top level entries the last sort of a SortDir left where
+// they were: the ones it skipped, and the ones it never sorts. The next sort
+// leaves the entries which have not changed since alone, without looking at
+// them again. The index is kept in the state directory of the SortDir.
+//
+// An index is only valid for the settings it was written with, see
+// Tidy.fingerprint, as different rules or folders may sort the same entries
+// differently.
+type index struct {
+	Config  string       `json:"config"`
+	Entries []indexEntry `json:"entries"`
+
+	// fs and dir are the filesystem and the directory of the index, which the
+	// destinations of the entries are checked against.
+	fs     afero.Fs
+	dir    string
+	byName map[string]indexEntry
+}
+
+// loadIndex reads the index of the directory dir. The index is empty if there is
+// none yet, or if it was written with settings other than config.
+func loadIndex(fsys afero.Fs, dir, config string) (*index, error) {
+	ix := &index{Config: config, fs: fsys, dir: dir, byName: make(map[string]indexEntry)}
+	b, err := afero.ReadFile(fsys, filepath.Join(dir, stateDirName, indexFileName))
+	if os.IsNotExist(err) {
+		return ix, nil
+	}
+	if err != nil {
+		return ix, err
+	}
+
+	var stored index
+	if err := json.Unmarshal(b, &stored); err != nil {
+		return ix, err
+	}
+	if stored.Config != config {
+		return ix, nil
+	}
+	for _, e := range stored.Entries {
+		ix.byName[e.Name] = e
+	}
+	return ix, nil
+}
+
+// unchanged returns the entry of the index for the entry described by info, if
+// it has not changed since the index was written, and the destination which
+// was in the way of it, if any, is still there.
+func (ix *index) unchanged(info fs.FileInfo) (indexEntry, bool) {
+	if ix == nil {
+		return indexEntry{}, false
+	}
+	e, ok := ix.byName[info.Name()]
+	if !ok || e.Size != info.Size() || !e.ModTime.Equal(info.ModTime()) || e.Inode != fileInode(info) {
+		return indexEntry{}, false
+	}
+	if e.Dest != "" {
+		dest := e.Dest
+		if !filepath.IsAbs(dest) {
+			dest = filepath.Join(ix.dir, dest)
+		}
+		if _, err := ix.fs.Stat(dest); err != nil {
+			return indexEntry{}, false
+		}
+	}
+	return e, true
+}
+
+// writeIndex replaces the index of the directory dir with ix. The index is
+// written to a temporary file first, so that an interrupted write never leaves
+// a broken index behind.
+func writeIndex(fsys afero.Fs, dir string, ix *index) error {
+	sort.Slice(ix.Entries, func(i, j int) bool { return ix.Entries[i].Name < ix.Entries[j].Name })
+	b, err := json.Marshal(ix)
+	if err != nil {
+		return err
+	}
+	stateDir := filepath.Join(dir, stateDirName)
+	if err := fsys.MkdirAll(stateDir, 0o755); err != nil {
+		return err
+	}
+	tmp := filepath.Join(stateDir, indexFileName+".tmp")
+	if err := afero.WriteFile(fsys, tmp, b, 0o644); err != nil {
+		return err
+	}
+	return fsys.Rename(tmp, filepath.Join(stateDir, indexFileName))
+}
+
+// removeIndex removes the index of the directory dir, if there is one.
+func removeIndex(fsys afero.Fs, dir string) error {
+	err := fsys.Remove(filepath.Join(dir, stateDirName, indexFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// fingerprint sums up the settings of t which decide where entries go, and
+// whether they are skipped.
+func (t *Tidy) fingerprint() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "dest=%s\ntemplate=%s\nconflict=%s\nrename=%v\n", t.DestDir, t.Template, t.OnConflict, t.RenameRules)
+	for _, rule := range t.Rules {
+		fmt.Fprintf(h, "rule=%s\n", rule)
+	}
+	for _, hook := range t.Hooks {
+		fmt.Fprintf(h, "hook=%s\n", hook)
+	}
+	if fts, ok := t.Sorter.(*FiletypeSorter); ok {
+		for _, v := range fts.Dirs {
+			fmt.Fprintf(h, "folder=%s dest=%s rename=%v\n", v.Name, v.Dest, v.Rename)
+		}
+		lookup := make([]string, 0, len(fts.Lookup))
+		for ext, v := range fts.Lookup {
+			lookup = append(lookup, ext+"="+v.Name)
+		}
+		sort.Strings(lookup)
+		fmt.Fprintf(h, "lookup=%s\n", strings.Join(lookup, ","))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// keep adds e, an entry the run leaves where it is, to the index the run writes
+// once it is done.
+func (r *run) keep(e indexEntry) {
+	r.keptMu.Lock()
+	defer r.keptMu.Unlock()
+	r.kept = append(r.kept, e)
+}
+
+// saveIndex writes the index of the SortDir, holding the entries the run left
+// where they are. Entries the run did not get to are left out, so the next run
+// looks at them again.
+func (r *run) saveIndex(config string) {
+	r.keptMu.Lock()
+	defer r.keptMu.Unlock()
+	if err := writeIndex(r.fs, r.sortDir, &index{Config: config, Entries: r.kept}); err != nil {
+		r.logger.Warn().Err(err).Msg("Could not write the index.")
+	}
+}
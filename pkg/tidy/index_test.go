@@ -0,0 +1,112 @@
+package tidy
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestSortIncrementally(t *testing.T) {
+	t.Log("Given the need to only look at the entries which changed since the last sort.")
+
+	testID := 0
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	// fsys is the SortDir, where the files of the test live.
+	fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+	for _, v := range []string{"photo.jpg", "Images/photo.jpg"} {
+		if err := afero.WriteFile(fsys, v, []byte(v), 0o644); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+	}
+
+	t.Logf("\tTest %d:\tWhen sorting a file whose destination is taken.", testID)
+	{
+		result, err := Tidy.Sort()
+		if err != nil || result.Skipped != 1 || result.Unchanged != 0 {
+			t.Fatalf("\t%s\tTest %d:\tShould skip the file, got %+v: %v", failed, testID, result, err)
+		}
+		if _, err := fsys.Stat(".tidy/index"); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould write the index: %v", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould skip the file and remember it in the index.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen sorting again without changing the file.", testID)
+	{
+		result, err := Tidy.Sort()
+		if err != nil || result.Skipped != 0 || result.Conflicts != 0 || result.Unchanged != 1 {
+			t.Fatalf("\t%s\tTest %d:\tShould leave the file alone, got %+v: %v", failed, testID, result, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould leave the unchanged file alone.", success, testID)
+
+		Tidy.Full = true
+		result, err = Tidy.Sort()
+		Tidy.Full = false
+		if err != nil || result.Skipped != 1 || result.Unchanged != 0 {
+			t.Fatalf("\t%s\tTest %d:\tShould look at the file again, got %+v: %v", failed, testID, result, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould look at every file in a full sort.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen the file changes.", testID)
+	{
+		if err := afero.WriteFile(fsys, "photo.jpg", []byte("a different photo"), 0o644); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to change the file: %v", failed, testID, err)
+		}
+		result, err := Tidy.Sort()
+		if err != nil || result.Skipped != 1 || result.Unchanged != 0 {
+			t.Fatalf("\t%s\tTest %d:\tShould look at the file again, got %+v: %v", failed, testID, result, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould look at the changed file again.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen the settings change.", testID)
+	{
+		Tidy.OnConflict = ConflictRename
+		result, err := Tidy.Sort()
+		if err != nil || result.Moved != 1 || result.Unchanged != 0 {
+			t.Fatalf("\t%s\tTest %d:\tShould sort the file with the new settings, got %+v: %v", failed, testID, result, err)
+		}
+		if _, err := fsys.Stat("Images/photo-2.jpg"); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould have renamed the file: %v", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould ignore an index written with other settings.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen the destination of a skipped file is freed.", testID)
+	{
+		Tidy.OnConflict = ConflictSkip
+		for _, v := range []string{"cat.jpg", "Images/cat.jpg"} {
+			if err := afero.WriteFile(fsys, v, []byte(v), 0o644); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+			}
+		}
+		if _, err := Tidy.Sort(); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to sort: %v", failed, testID, err)
+		}
+		result, err := Tidy.Sort()
+		if err != nil || result.Unchanged != 1 {
+			t.Fatalf("\t%s\tTest %d:\tShould leave the skipped file alone, got %+v: %v", failed, testID, result, err)
+		}
+
+		if err := fsys.Remove("Images/cat.jpg"); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to remove the destination: %v", failed, testID, err)
+		}
+		result, err = Tidy.Sort()
+		if err != nil || result.Moved != 1 || result.Unchanged != 0 {
+			t.Fatalf("\t%s\tTest %d:\tShould sort the file once its destination is free, got %+v: %v", failed, testID, result, err)
+		}
+		b, err := afero.ReadFile(fsys, "Images/cat.jpg")
+		if err != nil || string(b) != "cat.jpg" {
+			t.Fatalf("\t%s\tTest %d:\tShould have moved the file to its destination, got %q: %v", failed, testID, b, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould sort the file once its destination is free.", success, testID)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package tidy
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileInode returns the inode number of the file described by info, or 0 when
+// the filesystem does not report one.
+func fileInode(info fs.FileInfo) uint64 {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0
+	}
+	return uint64(st.Ino)
+}
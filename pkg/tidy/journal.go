@@ -0,0 +1,185 @@
+package tidy
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	// stateDirName is the directory inside the SortDir where tidy keeps its own
+	// state. It is never sorted.
+	stateDirName = ".tidy"
+
+	journalFileName = "journal.jsonl"
+)
+
+// JournalOp is the kind of change recorded by a JournalEntry.
+type JournalOp string
+
+const (
+	JournalBegin  JournalOp = "begin"
+	JournalMkdir  JournalOp = "mkdir"
+	JournalMove   JournalOp = "move"
+	JournalEnd    JournalOp = "end"
+	JournalUndone JournalOp = "undone"
+)
+
+// JournalEntry is a single line of the journal. Every change a sort makes to the
+// filesystem is recorded as an entry, which is what allows Undo to bring files
+// back from destinations outside of the SortDir.
+type JournalEntry struct {
+	Run  string    `json:"run"`
+	Op   JournalOp `json:"op"`
+	Src  string    `json:"src,omitempty"`
+	Dst  string    `json:"dst,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// journalRun groups the entries of a single sort.
+type journalRun struct {
+	ID       string
+	Started  time.Time
+	Entries  []JournalEntry
+	Finished bool
+	Undone   bool
+}
+
+// journal is an append-only JSON Lines file kept in the state directory of the
+// SortDir. Entries are written as they happen so that the journal stays
+// accurate even if tidy is interrupted part way through a sort. Paths inside of
+// the SortDir are recorded relative to it.
+type journal struct {
+	fs   afero.Fs
+	path string
+
+	// mu guards file, as the workers of a sort append to the journal at the
+	// same time.
+	mu   sync.Mutex
+	file afero.File
+}
+
+// newJournal returns the journal of the directory dir.
+func newJournal(fsys afero.Fs, dir string) *journal {
+	return &journal{fs: fsys, path: filepath.Join(dir, stateDirName, journalFileName)}
+}
+
+// exists reports whether a journal has been written for this directory.
+func (j *journal) exists() bool {
+	_, err := j.fs.Stat(j.path)
+	return err == nil
+}
+
+// append writes e to the end of the journal, creating the state directory and
+// the journal file if they do not exist yet.
+func (j *journal) append(e JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		if err := j.fs.MkdirAll(filepath.Dir(j.path), 0o755); err != nil {
+			return err
+		}
+		f, err := j.fs.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		j.file = f
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = j.file.Write(append(b, '\n'))
+	return err
+}
+
+func (j *journal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+// entries reads every entry of the journal, oldest first.
+func (j *journal) entries() ([]JournalEntry, error) {
+	f, err := j.fs.Open(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := make([]JournalEntry, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// runs reads the journal and returns every sort recorded in it, oldest first.
+func (j *journal) runs() ([]*journalRun, error) {
+	entries, err := j.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]*journalRun, 0)
+	byID := make(map[string]*journalRun)
+	for _, e := range entries {
+		jr, ok := byID[e.Run]
+		if !ok {
+			jr = &journalRun{ID: e.Run}
+			byID[e.Run] = jr
+			runs = append(runs, jr)
+		}
+		switch e.Op {
+		case JournalBegin:
+			jr.Started = e.Time
+		case JournalEnd:
+			jr.Finished = true
+		case JournalUndone:
+			jr.Undone = true
+		case JournalMkdir, JournalMove:
+			jr.Entries = append(jr.Entries, e)
+		}
+	}
+	return runs, nil
+}
+
+// pendingRuns returns the sorts in the journal that have not been undone yet,
+// oldest first.
+func (j *journal) pendingRuns() ([]*journalRun, error) {
+	runs, err := j.runs()
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]*journalRun, 0, len(runs))
+	for _, jr := range runs {
+		if !jr.Undone {
+			pending = append(pending, jr)
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,59 @@
+package tidy
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// Summary totals the events of one or more sorts. It is the last line written
+// by a JSONLinesSink, with its type set to "summary".
+type Summary struct {
+	Type string `json:"type"`
+	Runs int    `json:"runs"`
+	Counts
+
+	// Error is the error the command failed with, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// add counts e towards the summary.
+func (s *Summary) add(e Event) {
+	if e.Type == EventRunStarted {
+		s.Runs++
+	}
+	s.Counts.add(e)
+}
+
+// JSONLinesSink is an EventSink which writes every event to w as a single line
+// of JSON, for scripts to consume. Once done, WriteSummary writes the totals.
+type JSONLinesSink struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	summary Summary
+}
+
+// NewJSONLinesSink returns a JSONLinesSink writing to w.
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{enc: json.NewEncoder(w), summary: Summary{Type: "summary"}}
+}
+
+// Send writes e as a line of JSON.
+func (s *JSONLinesSink) Send(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.summary.add(e)
+	s.enc.Encode(e)
+}
+
+// WriteSummary writes the Summary of every event sent so far. err is the error
+// the command failed with, if any.
+func (s *JSONLinesSink) WriteSummary(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	summary := s.summary
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	return s.enc.Encode(summary)
+}
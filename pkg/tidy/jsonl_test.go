@@ -0,0 +1,70 @@
+package tidy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestJSONLinesSink(t *testing.T) {
+	t.Log("Given the need to report sort activity as JSON Lines for scripts.")
+
+	testID := 0
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	// fsys is the SortDir, where the files of the test live.
+	fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+	for _, v := range []string{"cat.jpg", "story.txt", "Documents/story.txt"} {
+		file, err := fsys.Create(v)
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+		file.Close()
+	}
+
+	var buf bytes.Buffer
+	sink := NewJSONLinesSink(&buf)
+	Tidy.Sinks = []EventSink{sink}
+
+	t.Logf("\tTest %d:\tWhen sorting with a conflicting file.", testID)
+	{
+		if _, err := Tidy.Sort(); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to sort: %v", failed, testID, err)
+		}
+		if err := sink.WriteSummary(errors.New("boom")); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to write the summary: %v", failed, testID, err)
+		}
+
+		var lines []map[string]any
+		scanner := bufio.NewScanner(&buf)
+		for scanner.Scan() {
+			var line map[string]any
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould write valid JSON on every line, got %q: %v", failed, testID, scanner.Text(), err)
+			}
+			lines = append(lines, line)
+		}
+		types := make(map[string]int)
+		for _, line := range lines {
+			types[line["type"].(string)]++
+		}
+		for typ, want := range map[string]int{"run_started": 1, "mkdir": 8, "conflict": 1, "skip": 1, "move": 1, "run_finished": 1, "summary": 1} {
+			if types[typ] != want {
+				t.Fatalf("\t%s\tTest %d:\tShould have written %d %s events, got %d", failed, testID, want, typ, types[typ])
+			}
+		}
+		t.Logf("\t%s\tTest %d:\tShould write an event for every action.", success, testID)
+
+		summary := lines[len(lines)-1]
+		if summary["type"] != "summary" || summary["moved"] != 1.0 || summary["skipped"] != 1.0 || summary["conflicts"] != 1.0 || summary["error"] != "boom" {
+			t.Fatalf("\t%s\tTest %d:\tShould end with the summary, got %v", failed, testID, summary)
+		}
+		t.Logf("\t%s\tTest %d:\tShould end with a summary of the events.", success, testID)
+	}
+}
@@ -0,0 +1,257 @@
+package tidy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	lockFileName = "lock"
+
+	// lockPoll is how often a run waiting for the lock of its SortDir checks
+	// whether it was released.
+	lockPoll = 100 * time.Millisecond
+
+	// takeoverAge is how old the guard of a takeover has to be before it is
+	// taken to be left behind by a process which crashed, see takeOver.
+	takeoverAge = 10 * time.Second
+)
+
+// errLockHeld is returned by flock when another open file holds the lock.
+var errLockHeld = errors.New("lock is held")
+
+// LockOwner describes the run of tidy which holds the lock of a SortDir, as
+// written to the lock file.
+type LockOwner struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Started time.Time `json:"started"`
+}
+
+// stale reports whether the process which took the lock is gone. Only processes
+// on this host can be checked, locks taken on other hosts are never stale, and
+// neither are locks on systems where processAlive can not tell.
+func (o LockOwner) stale() bool {
+	host, err := os.Hostname()
+	return err == nil && o.PID != 0 && o.Host == host && !processAlive(o.PID)
+}
+
+// same reports whether o and other describe the same run.
+func (o LockOwner) same(other LockOwner) bool {
+	return o.PID == other.PID && o.Host == other.Host && o.Started.Equal(other.Started)
+}
+
+// LockedError is returned when the SortDir is locked by another sort or undo,
+// which did not finish within Tidy.LockWait.
+type LockedError struct {
+	Path string
+
+	// Owner is the run holding the lock. It is empty if the lock file could
+	// not be read.
+	Owner LockOwner
+}
+
+func (e *LockedError) Error() string {
+	if e.Owner.PID == 0 {
+		return fmt.Sprintf("%s is locked by another run of tidy, remove %s if there is none", e.Path, filepath.Join(e.Path, stateDirName, lockFileName))
+	}
+	return fmt.Sprintf("%s is locked by process %d on %s since %s", e.Path, e.Owner.PID, e.Owner.Host, e.Owner.Started.Format(time.RFC3339))
+}
+
+// dirLock is the lock of a SortDir, held by a sort or an undo for as long as it
+// runs so that runs on the same directory never move files at the same time,
+// whether they are in this process or in another one.
+//
+// Where the filesystem supports it, the lock file is locked with flock, which
+// the system releases if the process crashes. Elsewhere the lock is held by
+// creating the lock file, and a lock file left behind by a process which is
+// gone is taken over.
+type dirLock struct {
+	fs   afero.Fs
+	path string
+
+	// file is the locked file, or nil if the lock is held by the existence of
+	// the lock file.
+	file afero.File
+}
+
+// lock takes the lock of the SortDir. If another run holds it, lock waits for
+// up to LockWait for it to be released, or until ctx is cancelled.
+//
+// The state directory is created if it is missing, callers which have nothing
+// to lock without one, such as an Undo, check for it first.
+func (t *Tidy) lock(ctx context.Context) (*dirLock, error) {
+	path := filepath.Join(t.SortDir, stateDirName, lockFileName)
+	if err := t.Fs.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(t.LockWait)
+	waiting := false
+	for {
+		l, err := t.tryLock(path)
+		var le *LockedError
+		if !errors.As(err, &le) {
+			return l, err
+		}
+		if t.LockWait == 0 || (t.LockWait > 0 && time.Now().After(deadline)) {
+			return nil, err
+		}
+		if !waiting {
+			t.logger.Info().Str("Directory", t.SortDir).Int("PID", le.Owner.PID).Msg("Waiting for another run to release the directory.")
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPoll):
+		}
+	}
+}
+
+// tryLock takes the lock at path without waiting, or returns a LockedError.
+func (t *Tidy) tryLock(path string) (*dirLock, error) {
+	if !canFlock(t.Fs) {
+		return t.createLock(path)
+	}
+	f, err := t.Fs.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := flock(f); err != nil {
+		f.Close()
+		if errors.Is(err, errLockHeld) {
+			return nil, &LockedError{Path: t.SortDir, Owner: readLockOwner(t.Fs, path)}
+		}
+		return nil, err
+	}
+
+	// the lock file is emptied when the lock is released, so an owner means
+	// the run which wrote it crashed.
+	if owner := readLockOwner(t.Fs, path); owner.PID != 0 {
+		t.logger.Warn().Str("Directory", t.SortDir).Int("PID", owner.PID).Time("Since", owner.Started).Msg("Took over the lock of a run which did not finish.")
+	}
+	l := &dirLock{fs: t.Fs, path: path, file: f}
+	b, err := newLockOwner()
+	if err == nil {
+		err = f.Truncate(0)
+	}
+	if err == nil {
+		_, err = f.WriteAt(b, 0)
+	}
+	if err != nil {
+		l.unlock()
+		return nil, err
+	}
+	return l, nil
+}
+
+// createLock takes the lock at path by creating the lock file, on filesystems
+// without flock.
+func (t *Tidy) createLock(path string) (*dirLock, error) {
+	f, err := t.Fs.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if os.IsExist(err) {
+		owner := readLockOwner(t.Fs, path)
+		if !owner.stale() {
+			return nil, &LockedError{Path: t.SortDir, Owner: owner}
+		}
+		f, err = t.takeOver(path, owner)
+	}
+	if err != nil {
+		return nil, err
+	}
+	l := &dirLock{fs: t.Fs, path: path}
+	b, err := newLockOwner()
+	if err == nil {
+		_, err = f.Write(b)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		l.unlock()
+		return nil, err
+	}
+	return l, nil
+}
+
+// takeOver replaces the lock file at path, which was left behind by owner, with
+// a new one. Runs which find the same stale lock at the same time take turns
+// through a guard file, and only the first of them replaces the lock file, the
+// others find the lock taken by it.
+func (t *Tidy) takeOver(path string, owner LockOwner) (afero.File, error) {
+	guard := path + ".takeover"
+	g, err := t.Fs.OpenFile(guard, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if os.IsExist(err) {
+		// a run which crashed while taking over leaves the guard behind.
+		if info, err := t.Fs.Stat(guard); err == nil && time.Since(info.ModTime()) > takeoverAge {
+			t.Fs.Remove(guard)
+		}
+		return nil, &LockedError{Path: t.SortDir, Owner: owner}
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.Close()
+	defer t.Fs.Remove(guard)
+
+	// another run may have taken over before the guard was created.
+	if current := readLockOwner(t.Fs, path); !current.same(owner) {
+		return nil, &LockedError{Path: t.SortDir, Owner: current}
+	}
+	t.logger.Warn().Str("Directory", t.SortDir).Int("PID", owner.PID).Time("Since", owner.Started).Msg("Took over the lock of a run which did not finish.")
+	if err := t.Fs.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	f, err := t.Fs.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if os.IsExist(err) {
+		return nil, &LockedError{Path: t.SortDir, Owner: readLockOwner(t.Fs, path)}
+	}
+	return f, err
+}
+
+// unlock releases the lock, logging the error if it could not be released
+// cleanly.
+func (t *Tidy) unlock(l *dirLock) {
+	if err := l.unlock(); err != nil {
+		t.logger.Warn().Err(err).Str("Directory", t.SortDir).Msg("Could not release the lock.")
+	}
+}
+
+// unlock releases the lock.
+func (l *dirLock) unlock() error {
+	if l.file == nil {
+		return l.fs.Remove(l.path)
+	}
+	// closing the file releases the flock.
+	err := l.file.Truncate(0)
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// newLockOwner returns the LockOwner of this process, encoded for the lock file.
+func newLockOwner() ([]byte, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(LockOwner{PID: os.Getpid(), Host: host, Started: time.Now()})
+}
+
+// readLockOwner returns the owner written to the lock file at path, or an empty
+// LockOwner if there is none.
+func readLockOwner(fsys afero.Fs, path string) LockOwner {
+	var owner LockOwner
+	if b, err := afero.ReadFile(fsys, path); err == nil {
+		json.Unmarshal(b, &owner)
+	}
+	return owner
+}
@@ -0,0 +1,33 @@
+//go:build !unix
+
+package tidy
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/afero"
+)
+
+// canFlock reports whether the files of fsys can be locked with flock, which is
+// never the case on this system.
+func canFlock(fsys afero.Fs) bool {
+	return false
+}
+
+func flock(f afero.File) error {
+	return errors.New("flock is not supported")
+}
+
+// processAlive reports whether the process pid is running. On Windows,
+// FindProcess opens the process, which fails if there is none. Elsewhere it
+// always succeeds, so processes are always taken to be running and locks left
+// behind have to be removed by hand, as the LockedError says.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
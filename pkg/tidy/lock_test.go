@@ -0,0 +1,169 @@
+package tidy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// deadPID is the PID of a process which does not exist.
+const deadPID = 1 << 30
+
+func TestLock(t *testing.T) {
+	t.Log("Given the need to keep two runs from sorting the same directory at once.")
+
+	host, err := os.Hostname()
+	if err != nil {
+		t.Fatalf("Should be able to get the hostname: %v", err)
+	}
+
+	for testID, fsys := range []afero.Fs{afero.NewOsFs(), afero.NewMemMapFs()} {
+		t.Logf("\tTest %d:\tWhen sorting a directory which is locked, on %s.", testID, fsys.Name())
+		{
+			dir := t.TempDir()
+			if err := fsys.MkdirAll(dir, 0o755); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+			}
+			if err := afero.WriteFile(fsys, filepath.Join(dir, "notes.txt"), nil, 0o644); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+			}
+			newTidy := func() *Tidy {
+				Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), fsys)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+				}
+				Tidy.SortDir = dir
+				return Tidy
+			}
+
+			holder := newTidy()
+			lock, err := holder.lock(context.Background())
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to lock the directory: %v", failed, testID, err)
+			}
+
+			Tidy := newTidy()
+			_, err = Tidy.Sort()
+			var le *LockedError
+			if !errors.As(err, &le) || le.Owner.PID != os.Getpid() || le.Owner.Host != host {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse to sort, naming the process holding the lock, got %v", failed, testID, err)
+			}
+			if err := Tidy.Undo(); !errors.As(err, &le) {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse to undo, got %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse to sort and undo, naming the process holding the lock.", success, testID)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			Tidy.LockWait = -1
+			if _, err := Tidy.SortContext(ctx); !errors.Is(err, context.Canceled) {
+				t.Fatalf("\t%s\tTest %d:\tShould stop waiting once cancelled, got %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould stop waiting once cancelled.", success, testID)
+
+			Tidy.LockWait = 5 * time.Second
+			time.AfterFunc(200*time.Millisecond, func() { lock.unlock() })
+			if _, err := Tidy.Sort(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould sort once the lock is released: %v", failed, testID, err)
+			}
+			if _, err := fsys.Stat(filepath.Join(dir, "Documents", "notes.txt")); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould sort once the lock is released: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould sort once the lock is released.", success, testID)
+
+			if owner := readLockOwner(fsys, filepath.Join(dir, stateDirName, lockFileName)); owner.PID != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould release the lock after sorting, held by %+v", failed, testID, owner)
+			}
+			t.Logf("\t%s\tTest %d:\tShould release the lock after sorting.", success, testID)
+		}
+	}
+
+	testID := 2
+	for _, fsys := range []afero.Fs{afero.NewOsFs(), afero.NewMemMapFs()} {
+		t.Logf("\tTest %d:\tWhen the lock was left by a process which is gone, on %s.", testID, fsys.Name())
+		{
+			dir := t.TempDir()
+			Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), fsys)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+			}
+			Tidy.SortDir = dir
+			b, _ := json.Marshal(LockOwner{PID: deadPID, Host: host, Started: time.Now().Add(-time.Hour)})
+			if err := fsys.MkdirAll(filepath.Join(dir, stateDirName), 0o755); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+			}
+			if err := afero.WriteFile(fsys, filepath.Join(dir, stateDirName, lockFileName), b, 0o644); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+			}
+
+			if _, err := Tidy.Sort(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould take over the stale lock: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould take over the stale lock.", success, testID)
+		}
+		testID++
+	}
+
+	t.Logf("\tTest %d:\tWhen several runs take over the same stale lock at once.", testID)
+	{
+		fsys := afero.NewMemMapFs()
+		dir := t.TempDir()
+		path := filepath.Join(dir, stateDirName, lockFileName)
+		b, _ := json.Marshal(LockOwner{PID: deadPID, Host: host, Started: time.Now().Add(-time.Hour)})
+		if err := fsys.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+		if err := afero.WriteFile(fsys, path, b, 0o644); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+
+		var wg sync.WaitGroup
+		var taken atomic.Int32
+		for i := 0; i < 8; i++ {
+			Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), fsys)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+			}
+			Tidy.SortDir = dir
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := Tidy.tryLock(path); err == nil {
+					taken.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if n := taken.Load(); n != 1 {
+			t.Fatalf("\t%s\tTest %d:\tShould let exactly one run take over, got %d", failed, testID, n)
+		}
+		t.Logf("\t%s\tTest %d:\tShould let exactly one run take over.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen undoing a directory without any state.", testID)
+	{
+		Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+		}
+		if err := Tidy.Fs.MkdirAll(Tidy.SortDir, 0o755); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+		if err := Tidy.Undo(); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to undo: %v", failed, testID, err)
+		}
+		if _, err := Tidy.Fs.Stat(filepath.Join(Tidy.SortDir, stateDirName)); err == nil {
+			t.Fatalf("\t%s\tTest %d:\tShould not create the state directory", failed, testID)
+		}
+		t.Logf("\t%s\tTest %d:\tShould not create the state directory.", success, testID)
+	}
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package tidy
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/spf13/afero"
+)
+
+// canFlock reports whether the files of fsys can be locked with flock.
+func canFlock(fsys afero.Fs) bool {
+	_, ok := fsys.(*afero.OsFs)
+	return ok
+}
+
+// flock locks f without waiting, and returns errLockHeld if another open file
+// holds the lock. The lock is released when f is closed.
+func flock(f afero.File) error {
+	osf, ok := f.(*os.File)
+	if !ok {
+		return syscall.ENOTSUP
+	}
+	err := syscall.Flock(int(osf.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return errLockHeld
+	}
+	return err
+}
+
+// processAlive reports whether the process pid is running.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package tidy
+
+import (
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// isMountRoot reports whether dir is the root of a volume, such as C:\.
+func isMountRoot(fsys afero.Fs, dir string) bool {
+	return filepath.Dir(dir) == dir
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package tidy
+
+import (
+	"path/filepath"
+	"syscall"
+
+	"github.com/spf13/afero"
+)
+
+// isMountRoot reports whether dir is the root of a mounted filesystem, which is
+// the case when it lives on a different device than its parent.
+func isMountRoot(fsys afero.Fs, dir string) bool {
+	info, err := fsys.Stat(dir)
+	if err != nil {
+		return false
+	}
+	parent, err := fsys.Stat(filepath.Dir(dir))
+	if err != nil {
+		return false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	pst, pok := parent.Sys().(*syscall.Stat_t)
+	return ok && pok && st.Dev != pst.Dev
+}
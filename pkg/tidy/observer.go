@@ -0,0 +1,280 @@
+package tidy
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Observer is notified of every change a sort or an undo makes, with a method
+// and a typed event for every kind of change. Add it to a Tidy with Observe.
+//
+// Observers are called synchronously, one event at a time, like EventSinks.
+// Embed NopObserver to only implement the methods you need.
+type Observer interface {
+	// RunStarted is called when a sort starts.
+	RunStarted(e RunStartedEvent)
+
+	// RunFinished is called when a sort is done, whether it succeeded or not.
+	RunFinished(e RunFinishedEvent)
+
+	// Mkdir is called for every directory created, such as the scaffolding.
+	Mkdir(e MkdirEvent)
+
+	// Move is called for every entry moved into its folder.
+	Move(e MoveEvent)
+
+	// Skip is called for every entry left where it is, although it would have
+	// been moved.
+	Skip(e SkipEvent)
+
+	// Conflict is called when something already exists at the destination of an
+	// entry, before the conflict policy is applied.
+	Conflict(e ConflictEvent)
+
+	// Error is called for every entry which could not be sorted.
+	Error(e ErrorEvent)
+
+	// Restore is called for every entry an undo brings back to where it was
+	// sorted from.
+	Restore(e RestoreEvent)
+
+	// Rmdir is called for every directory an undo removes.
+	Rmdir(e RmdirEvent)
+
+	// Clash is called when a file is in the way of a sorting folder, once the
+	// clash policy was applied to it.
+	Clash(e ClashEvent)
+
+	// HookFailed is called when the on_move hook of an entry fails, after the
+	// entry was moved.
+	HookFailed(e HookFailedEvent)
+}
+
+// EventInfo holds the fields every typed event shares.
+type EventInfo struct {
+	Run     string
+	Time    time.Time
+	SortDir string
+}
+
+// RunStartedEvent reports a sort which started.
+type RunStartedEvent struct {
+	EventInfo
+}
+
+// RunFinishedEvent reports a sort which is done. Moved is the number of entries
+// it moved, and Err the error it failed with, if any.
+type RunFinishedEvent struct {
+	EventInfo
+	Moved int
+	Err   error
+}
+
+// MkdirEvent reports a directory which was created.
+type MkdirEvent struct {
+	EventInfo
+	Dir string
+}
+
+// MoveEvent reports an entry which was moved into its folder.
+type MoveEvent struct {
+	EventInfo
+
+	// File is the name of the entry, and Dest the absolute path it was moved to.
+	File string
+	Dest string
+
+	IsDir          bool
+	KnownExtension bool
+
+	// Category is the folder the entry was sorted into, and Size its size in
+	// bytes, which is 0 for directories.
+	Category string
+	Size     int64
+}
+
+// SkipEvent reports an entry which was left where it is.
+type SkipEvent struct {
+	EventInfo
+	File   string
+	Dest   string
+	Reason string
+}
+
+// ConflictEvent reports an entry whose destination already exists.
+type ConflictEvent struct {
+	EventInfo
+	File   string
+	Dest   string
+	Policy ConflictPolicy
+}
+
+// ErrorEvent reports an entry which could not be sorted.
+type ErrorEvent struct {
+	EventInfo
+	File string
+	Err  error
+}
+
+// RestoreEvent reports an entry which an undo brought back. Dest is the
+// absolute path it was brought back to.
+type RestoreEvent struct {
+	EventInfo
+	File string
+	Dest string
+}
+
+// RmdirEvent reports a directory which an undo removed.
+type RmdirEvent struct {
+	EventInfo
+	Dir string
+}
+
+// ClashEvent reports a file which was in the way of the sorting folder
+// Category. Dest is where the file was moved, or with ClashRenameFolder, the
+// folder which is used instead.
+type ClashEvent struct {
+	EventInfo
+	File     string
+	Dest     string
+	Category string
+	Policy   ClashPolicy
+}
+
+// HookFailedEvent reports an entry which was moved to Dest, but whose on_move
+// hook failed with Err.
+type HookFailedEvent struct {
+	EventInfo
+	File     string
+	Dest     string
+	Category string
+	Err      error
+}
+
+// NopObserver implements Observer and ignores every event.
+type NopObserver struct{}
+
+func (NopObserver) RunStarted(RunStartedEvent)   {}
+func (NopObserver) RunFinished(RunFinishedEvent) {}
+func (NopObserver) Mkdir(MkdirEvent)             {}
+func (NopObserver) Move(MoveEvent)               {}
+func (NopObserver) Skip(SkipEvent)               {}
+func (NopObserver) Conflict(ConflictEvent)       {}
+func (NopObserver) Error(ErrorEvent)             {}
+func (NopObserver) Restore(RestoreEvent)         {}
+func (NopObserver) Rmdir(RmdirEvent)             {}
+func (NopObserver) Clash(ClashEvent)             {}
+func (NopObserver) HookFailed(HookFailedEvent)   {}
+
+// ObserverSink returns an EventSink which passes the events it receives on to
+// o as typed events, every type of event has a method on Observer.
+func ObserverSink(o Observer) EventSink {
+	return observerSink{o: o}
+}
+
+type observerSink struct {
+	o Observer
+}
+
+func (s observerSink) Send(e Event) {
+	info := EventInfo{Run: e.Run, Time: e.Time, SortDir: e.SortDir}
+	switch e.Type {
+	case EventRunStarted:
+		s.o.RunStarted(RunStartedEvent{EventInfo: info})
+	case EventRunFinished:
+		var err error
+		if e.Error != "" {
+			err = eventErr(e)
+		}
+		s.o.RunFinished(RunFinishedEvent{EventInfo: info, Moved: e.Moved, Err: err})
+	case EventMkdir:
+		s.o.Mkdir(MkdirEvent{EventInfo: info, Dir: e.Dest})
+	case EventMove:
+		s.o.Move(MoveEvent{EventInfo: info, File: e.File, Dest: e.Dest, IsDir: e.IsDir, KnownExtension: e.KnownExtension, Category: e.Category, Size: e.Size})
+	case EventSkip:
+		s.o.Skip(SkipEvent{EventInfo: info, File: e.File, Dest: e.Dest, Reason: e.Reason})
+	case EventConflict:
+		s.o.Conflict(ConflictEvent{EventInfo: info, File: e.File, Dest: e.Dest, Policy: e.Policy})
+	case EventError:
+		s.o.Error(ErrorEvent{EventInfo: info, File: e.File, Err: eventErr(e)})
+	case EventRestore:
+		s.o.Restore(RestoreEvent{EventInfo: info, File: e.File, Dest: e.Dest})
+	case EventRmdir:
+		s.o.Rmdir(RmdirEvent{EventInfo: info, Dir: e.Dest})
+	case EventClash:
+		s.o.Clash(ClashEvent{EventInfo: info, File: e.File, Dest: e.Dest, Category: e.Category, Policy: e.Clash})
+	case EventHookFailed:
+		s.o.HookFailed(HookFailedEvent{EventInfo: info, File: e.File, Dest: e.Dest, Category: e.Category, Err: eventErr(e)})
+	}
+}
+
+// eventErr returns the error behind e, or one made from its Error when e was not
+// sent by this process.
+func eventErr(e Event) error {
+	if e.err != nil {
+		return e.err
+	}
+	return errors.New(e.Error)
+}
+
+// Observe adds o to the Sinks of t, see ObserverSink.
+func (t *Tidy) Observe(o Observer) {
+	t.Sinks = append(t.Sinks, ObserverSink(o))
+}
+
+// LogObserver is an Observer which logs every event. NewTidy adds one to the
+// Sinks of every Tidy, remove it to sort without logging.
+type LogObserver struct {
+	logger zerolog.Logger
+}
+
+// NewLogObserver returns a LogObserver which logs to l.
+func NewLogObserver(l zerolog.Logger) *LogObserver {
+	return &LogObserver{logger: l}
+}
+
+func (lo *LogObserver) Mkdir(e MkdirEvent) {
+	lo.logger.Debug().Str("Directory", e.Dir).Msg("Created directory.")
+}
+
+func (lo *LogObserver) Move(e MoveEvent) {
+	lo.logger.Info().Str("Moved", e.File).Str("New Path", e.Dest).Bool("Is Dir", e.IsDir).Bool("Known Extension", e.KnownExtension).Msg("Sorted file to new directory.")
+}
+
+func (lo *LogObserver) Skip(e SkipEvent) {
+	lo.logger.Warn().Str("Skipped", e.File).Str("Path", e.Dest).Str("Reason", e.Reason).Msg("Skipped file.")
+}
+
+func (lo *LogObserver) Conflict(e ConflictEvent) {
+	lo.logger.Debug().Str("File", e.File).Str("Conflicting Path", e.Dest).Str("Policy", string(e.Policy)).Msg("The destination already exists.")
+}
+
+func (lo *LogObserver) Error(e ErrorEvent) {
+	lo.logger.Error().Err(e.Err).Str("File", e.File).Msg("Could not sort file.")
+}
+
+func (lo *LogObserver) Restore(e RestoreEvent) {
+	lo.logger.Info().Str("Moved", e.File).Str("New Path", e.Dest).Msg("Unsorted file to original location.")
+}
+
+func (lo *LogObserver) Rmdir(e RmdirEvent) {
+	lo.logger.Info().Str("Deleted Directory", e.Dir).Msg("Deleted directory.")
+}
+
+func (lo *LogObserver) Clash(e ClashEvent) {
+	lo.logger.Warn().Str("File", e.File).Str("Folder", e.Category).Str("New Path", e.Dest).Str("Policy", string(e.Policy)).Msg("A file was in the way of a sorting folder.")
+}
+
+func (lo *LogObserver) RunStarted(e RunStartedEvent) {
+	lo.logger.Debug().Str("Run", e.Run).Str("Directory", e.SortDir).Msg("Started sorting.")
+}
+
+func (lo *LogObserver) RunFinished(e RunFinishedEvent) {
+	lo.logger.Debug().Err(e.Err).Str("Run", e.Run).Int("Moved", e.Moved).Msg("Finished sorting.")
+}
+
+func (lo *LogObserver) HookFailed(e HookFailedEvent) {
+	lo.logger.Warn().Err(e.Err).Str("File", e.File).Str("New Path", e.Dest).Msg("Sorted file, but its on_move hook failed.")
+}
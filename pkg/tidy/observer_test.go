@@ -0,0 +1,91 @@
+package tidy
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+)
+
+// recordingObserver records the events it receives, by the name of their method.
+type recordingObserver struct {
+	NopObserver
+	events []string
+	err    error
+}
+
+func (o *recordingObserver) Move(e MoveEvent) { o.events = append(o.events, "move "+e.File) }
+func (o *recordingObserver) Skip(e SkipEvent) { o.events = append(o.events, "skip "+e.File) }
+func (o *recordingObserver) Conflict(e ConflictEvent) {
+	o.events = append(o.events, "conflict "+e.File)
+}
+func (o *recordingObserver) Restore(e RestoreEvent) { o.events = append(o.events, "restore "+e.File) }
+func (o *recordingObserver) RunStarted(e RunStartedEvent) {
+	o.events = append(o.events, "run started")
+}
+func (o *recordingObserver) RunFinished(e RunFinishedEvent) {
+	o.events = append(o.events, "run finished")
+}
+func (o *recordingObserver) HookFailed(e HookFailedEvent) {
+	o.events = append(o.events, "hook failed "+e.File)
+}
+
+func (o *recordingObserver) Error(e ErrorEvent) {
+	o.events = append(o.events, "error "+e.File)
+	o.err = e.Err
+}
+
+func TestObserver(t *testing.T) {
+	t.Log("Given the need to react to every change a sort makes.")
+
+	testID := 0
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), &renameFailingFs{Fs: afero.NewMemMapFs(), fail: map[string]bool{"c.txt": true}})
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	// fsys is the SortDir, where the files of the test live.
+	fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+	for _, v := range []string{"a.jpg", "b.jpg", "Images/b.jpg", "c.txt"} {
+		if err := afero.WriteFile(fsys, v, nil, 0o644); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+	}
+	hook, err := NewHook(HookOnMove, "Images", "test {name} != a.jpg")
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create the hook: %v", failed, testID, err)
+	}
+	Tidy.Hooks = []*Hook{hook}
+	o := &recordingObserver{}
+	Tidy.Observe(o)
+
+	t.Logf("\tTest %d:\tWhen sorting.", testID)
+	{
+		Tidy.Sort()
+		want := []string{"run started", "move a.jpg", "hook failed a.jpg", "conflict b.jpg", "skip b.jpg", "error c.txt", "run finished"}
+		if !cmp.Equal(o.events, want) {
+			t.Fatalf("\t%s\tTest %d:\tShould receive %v, got %v", failed, testID, want, o.events)
+		}
+		t.Logf("\t%s\tTest %d:\tShould receive an event for every entry.", success, testID)
+
+		if !errors.Is(o.err, fs.ErrPermission) {
+			t.Fatalf("\t%s\tTest %d:\tShould receive the error of the move, got %v", failed, testID, o.err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould receive the error of the move.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen undoing.", testID)
+	{
+		o.events = nil
+		if err := Tidy.Undo(); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to undo: %v", failed, testID, err)
+		}
+		want := []string{"restore a.jpg"}
+		if !cmp.Equal(o.events, want) {
+			t.Fatalf("\t%s\tTest %d:\tShould receive %v, got %v", failed, testID, want, o.events)
+		}
+		t.Logf("\t%s\tTest %d:\tShould receive an event for every file brought back.", success, testID)
+	}
+}
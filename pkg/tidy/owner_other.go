@@ -0,0 +1,11 @@
+//go:build !unix
+
+package tidy
+
+import "io/fs"
+
+// fileOwner is not supported outside of unix systems, so files never have an
+// owner there.
+func fileOwner(info fs.FileInfo) string {
+	return ""
+}
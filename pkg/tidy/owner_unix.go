@@ -0,0 +1,25 @@
+//go:build unix
+
+package tidy
+
+import (
+	"io/fs"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// fileOwner returns the name of the user owning the file described by info, or
+// their uid if the name can not be looked up. An empty string is returned when
+// the filesystem does not report an owner.
+func fileOwner(info fs.FileInfo) string {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	uid := strconv.FormatUint(uint64(st.Uid), 10)
+	if u, err := user.LookupId(uid); err == nil {
+		return u.Username
+	}
+	return uid
+}
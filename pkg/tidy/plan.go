@@ -0,0 +1,196 @@
+package tidy
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/spf13/afero"
+)
+
+// plannedMove is a move a sort decided on while planning, which is carried out
+// once every entry of the SortDir is planned.
+type plannedMove struct {
+	path string
+	info fs.FileInfo
+	c    *classification
+
+	// dest is where the entry goes, before the conflict policy is applied.
+	dest string
+}
+
+// plan decides where every entry of the SortDir goes, and returns the moves
+// which are to be made in the order of the names of the entries. Entries the
+// index of the run has seen unchanged are left alone. The entries
+// which could not be planned are returned as SortingErrors, unless the run fails
+// fast, in which case the first of them is returned as err along with the moves
+// planned so far. When the context of the run is cancelled, the entries which
+// were not planned yet, but would have been moved, are counted as remaining.
+func (fts *FiletypeSorter) plan(r *run) (moves []*plannedMove, errs SortingErrors, err error) {
+	entries, err := afero.ReadDir(r.fs, r.sortDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	moves = make([]*plannedMove, 0, len(entries))
+	for i, f := range entries {
+		if err := r.ctx.Err(); err != nil {
+			r.result.Remaining = r.unplanned(entries[i:])
+			return moves, errs, err
+		}
+		if e, ok := r.index.unchanged(f); ok {
+			r.keep(e)
+			if e.Category != "" {
+				r.result.Unchanged++
+			}
+			continue
+		}
+		m, se := fts.planEntry(r, filepath.Join(r.sortDir, f.Name()), f)
+		if se != nil {
+			r.emit(Event{Type: EventError, File: f.Name(), err: se})
+			if r.failFast {
+				return moves, errs, se
+			}
+			errs = append(errs, se)
+		}
+		if m != nil {
+			moves = append(moves, m)
+		} else if se == nil {
+			r.keep(newIndexEntry(f, ""))
+		}
+	}
+	return moves, errs, nil
+}
+
+// unplanned returns how many of entries a sort would move, without classifying
+// them. classify only leaves the entries tidy keeps and the sorting folders
+// where they are, and the index the entries which have not changed.
+func (r *run) unplanned(entries []fs.FileInfo) int {
+	n := 0
+	for _, f := range entries {
+		if r.ignore[f.Name()] || f.IsDir() && r.folders[f.Name()] {
+			continue
+		}
+		if _, ok := r.index.unchanged(f); ok {
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// planEntry classifies the entry at path, described by f, and works out where
+// it goes. A nil move means the entry is left where it is.
+func (fts *FiletypeSorter) planEntry(r *run, path string, f fs.FileInfo) (*plannedMove, *SortingError) {
+	c, err := fts.classify(r, path, f)
+	if err != nil {
+		return nil, &SortingError{Filename: f.Name(), AbsPath: path, Sort: true, Err: err}
+	}
+	if c.folder == nil {
+		return nil, nil
+	}
+	dest, err := r.destination(c.folder, f)
+	if err != nil {
+		return nil, &SortingError{Filename: f.Name(), AbsPath: r.folderPath(c.folder), Sort: true, Err: err}
+	}
+	return &plannedMove{path: path, info: f, c: c, dest: dest}, nil
+}
+
+// renamedSuffixRegexp matches the counters ConflictRename adds to names.
+var renamedSuffixRegexp = regexp.MustCompile(`(-\d+)+$`)
+
+// conflictKey returns the key of the destinations which can conflict with dest:
+// dest itself, the names ConflictRename picks for it, and the names which only
+// differ from it by case, for filesystems which ignore case.
+func conflictKey(dest string) string {
+	ext := filepath.Ext(dest)
+	stem := renamedSuffixRegexp.ReplaceAllString(strings.TrimSuffix(dest, ext), "")
+	return strings.ToLower(stem + ext)
+}
+
+// execute carries out the planned moves with move, running up to r.workers of
+// them at a time. Moves which can conflict with each other, see conflictKey, are
+// carried out one after another in the order they were planned, so conflicts
+// are resolved the same way no matter how many workers there are.
+//
+// The errors of the moves which failed are returned in the order of the moves,
+// along with the number of moves which were never started. No more moves are
+// started once the context of the run is cancelled, or once a move has failed
+// when the run fails fast.
+func (r *run) execute(moves []*plannedMove, move func(m *plannedMove) error) (SortingErrors, int) {
+	errs := make([]error, len(moves))
+	started := make([]bool, len(moves))
+	var failed atomic.Bool
+	do := func(i int) {
+		if r.failFast && failed.Load() || r.ctx.Err() != nil {
+			return
+		}
+		started[i] = true
+		if err := move(moves[i]); err != nil {
+			errs[i] = err
+			failed.Store(true)
+		}
+	}
+
+	if r.workers <= 1 {
+		for i := range moves {
+			do(i)
+		}
+	} else {
+		groups := make([][]int, 0)
+		byKey := make(map[string]int)
+		for i, m := range moves {
+			key := conflictKey(m.dest)
+			g, ok := byKey[key]
+			if !ok {
+				g = len(groups)
+				byKey[key] = g
+				groups = append(groups, nil)
+			}
+			groups[g] = append(groups[g], i)
+		}
+
+		workers := r.workers
+		if len(groups) < workers {
+			workers = len(groups)
+		}
+		queue := make(chan []int)
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for g := range queue {
+					for _, i := range g {
+						do(i)
+					}
+				}
+			}()
+		}
+		for _, g := range groups {
+			queue <- g
+		}
+		close(queue)
+		wg.Wait()
+	}
+
+	var failures SortingErrors
+	remaining := 0
+	for i, err := range errs {
+		if !started[i] {
+			remaining++
+		}
+		if err == nil {
+			continue
+		}
+		var se *SortingError
+		if !errors.As(err, &se) {
+			se = &SortingError{Filename: moves[i].info.Name(), AbsPath: moves[i].path, Sort: true, Err: err}
+		}
+		failures = append(failures, se)
+	}
+	return failures, remaining
+}
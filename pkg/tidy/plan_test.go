@@ -0,0 +1,231 @@
+package tidy
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/afero"
+)
+
+func TestSortWithWorkers(t *testing.T) {
+	t.Log("Given the need to move files in parallel without changing how conflicts are resolved.")
+
+	testID := 0
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	// fsys is the SortDir, where the files of the test live.
+	fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+	Tidy.Workers = 8
+	Tidy.OnConflict = ConflictRename
+
+	files := []string{"PDFs/report.pdf", "report.pdf", "report-2.pdf", "REPORT.pdf"}
+	for i := 0; i < 200; i++ {
+		files = append(files, fmt.Sprintf("photo-%03d.jpg", i), fmt.Sprintf("note-%03d.txt", i), fmt.Sprintf("song-%03d.mp3", i))
+	}
+	for _, v := range files {
+		if err := afero.WriteFile(fsys, v, []byte(v), 0o644); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+	}
+
+	t.Logf("\tTest %d:\tWhen sorting with 8 workers.", testID)
+	{
+		result, err := Tidy.Sort()
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to sort: %v", failed, testID, err)
+		}
+		if result.Moved != len(files)-1 {
+			t.Fatalf("\t%s\tTest %d:\tShould have moved %d files, got %d", failed, testID, len(files)-1, result.Moved)
+		}
+		t.Logf("\t%s\tTest %d:\tShould move every file.", success, testID)
+
+		// the files are planned in order of their names, the same order a single
+		// worker moves them in.
+		for dest, src := range map[string]string{
+			"PDFs/report.pdf":   "PDFs/report.pdf",
+			"PDFs/REPORT.pdf":   "REPORT.pdf",
+			"PDFs/report-2.pdf": "report-2.pdf",
+			"PDFs/report-3.pdf": "report.pdf",
+		} {
+			got, err := afero.ReadFile(fsys, dest)
+			if err != nil || string(got) != src {
+				t.Fatalf("\t%s\tTest %d:\tShould have moved %s to %s, got %q: %v", failed, testID, src, dest, got, err)
+			}
+		}
+		t.Logf("\t%s\tTest %d:\tShould resolve conflicts in the order the files were planned.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen undoing the parallel sort.", testID)
+	{
+		if err := Tidy.Undo(); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to undo: %v", failed, testID, err)
+		}
+		for _, v := range files {
+			if _, err := fsys.Stat(v); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould have brought back %s: %v", failed, testID, v, err)
+			}
+		}
+		if _, err := fsys.Stat("Images"); err == nil {
+			t.Fatalf("\t%s\tTest %d:\tShould have removed the scaffolding.", failed, testID)
+		}
+		t.Logf("\t%s\tTest %d:\tShould bring back every file.", success, testID)
+	}
+}
+
+// latencyFs is an afero.Fs which waits before every Stat and Rename, the way a
+// network filesystem waits for the server to answer.
+type latencyFs struct {
+	afero.Fs
+	latency time.Duration
+}
+
+func (fsys *latencyFs) Stat(name string) (fs.FileInfo, error) {
+	time.Sleep(fsys.latency)
+	return fsys.Fs.Stat(name)
+}
+
+func (fsys *latencyFs) Rename(oldname, newname string) error {
+	time.Sleep(fsys.latency)
+	return fsys.Fs.Rename(oldname, newname)
+}
+
+// BenchmarkSort sorts directories of 1k and 10k files on the filesystem of the
+// OS, with and without the latency of a network filesystem, using 1, 4 and 16
+// workers. A MemMapFs is no use here, as every rename on it walks every file it
+// holds.
+func BenchmarkSort(b *testing.B) {
+	// logging every move would dominate the benchmark.
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	b.Cleanup(func() { zerolog.SetGlobalLevel(level) })
+
+	for _, n := range []int{1_000, 10_000} {
+		for _, latency := range []time.Duration{0, 200 * time.Microsecond} {
+			for _, workers := range []int{1, 4, 16} {
+				name := fmt.Sprintf("files=%d/latency=%s/workers=%d", n, latency, workers)
+				b.Run(name, func(b *testing.B) { benchmarkSort(b, n, latency, workers) })
+			}
+		}
+	}
+}
+
+// benchmarkSort sorts a directory of n files with the given number of workers.
+func benchmarkSort(b *testing.B, n int, latency time.Duration, workers int) {
+	exts := []string{"jpg", "txt", "mp3", "pdf", "zip", "go", "mkv", "xxx"}
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), &latencyFs{Fs: afero.NewOsFs(), latency: latency})
+		if err != nil {
+			b.Fatal(err)
+		}
+		Tidy.SortDir = b.TempDir()
+		Tidy.Workers = workers
+		for j := 0; j < n; j++ {
+			if err := os.WriteFile(fmt.Sprintf("%s/file-%06d.%s", Tidy.SortDir, j, exts[j%len(exts)]), nil, 0o644); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.StartTimer()
+
+		if _, err := Tidy.Sort(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkPlan plans the sort of directories of 10k, 100k and 1M entries, one
+// in a hundred of which is a directory. Planning looks at every entry once, so
+// the allocations per entry stay the same as the directories grow.
+func BenchmarkPlan(b *testing.B) {
+	exts := []string{"jpg", "txt", "mp3", "pdf", "zip", "go", "mkv", "xxx"}
+	for _, n := range []int{10_000, 100_000, 1_000_000} {
+		b.Run(fmt.Sprintf("entries=%d", n), func(b *testing.B) {
+			Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+			if err != nil {
+				b.Fatal(err)
+			}
+			for j := 0; j < n; j++ {
+				path := fmt.Sprintf("%s/entry-%07d", Tidy.SortDir, j)
+				if j%100 == 0 {
+					err = Tidy.Fs.Mkdir(path, 0o755)
+				} else {
+					err = afero.WriteFile(Tidy.Fs, path+"."+exts[j%len(exts)], nil, 0o644)
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			fts := Tidy.Sorter.(*FiletypeSorter)
+			b.ResetTimer()
+			start := time.Now()
+
+			for i := 0; i < b.N; i++ {
+				moves, _, err := fts.plan(Tidy.newRun())
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(moves) != n {
+					b.Fatalf("planned %d moves, want %d", len(moves), n)
+				}
+			}
+			b.ReportMetric(float64(time.Since(start).Nanoseconds())/float64(b.N*n), "ns/entry")
+		})
+	}
+}
+
+// BenchmarkSortUndo sorts and then undoes directories of 10k and 100k files on
+// the filesystem of the OS, reporting the time per entry of the sort, planning
+// and moving, and of the undo separately. A MemMapFs is no use here, as every
+// rename on it walks every file it holds.
+func BenchmarkSortUndo(b *testing.B) {
+	// logging every move would dominate the benchmark.
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	b.Cleanup(func() { zerolog.SetGlobalLevel(level) })
+
+	exts := []string{"jpg", "txt", "mp3", "pdf", "zip", "go", "mkv", "xxx"}
+	for _, n := range []int{10_000, 100_000} {
+		b.Run(fmt.Sprintf("entries=%d", n), func(b *testing.B) {
+			var sorting, undoing time.Duration
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewOsFs())
+				if err != nil {
+					b.Fatal(err)
+				}
+				Tidy.SortDir = b.TempDir()
+				for j := 0; j < n; j++ {
+					if err := os.WriteFile(fmt.Sprintf("%s/file-%07d.%s", Tidy.SortDir, j, exts[j%len(exts)]), nil, 0o644); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.StartTimer()
+
+				start := time.Now()
+				result, err := Tidy.Sort()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if result.Moved != n {
+					b.Fatalf("moved %d files, want %d", result.Moved, n)
+				}
+				sorting += time.Since(start)
+
+				start = time.Now()
+				if err := Tidy.Undo(); err != nil {
+					b.Fatal(err)
+				}
+				undoing += time.Since(start)
+			}
+			b.ReportMetric(float64(sorting.Nanoseconds())/float64(b.N*n), "sort-ns/entry")
+			b.ReportMetric(float64(undoing.Nanoseconds())/float64(b.N*n), "undo-ns/entry")
+		})
+	}
+}
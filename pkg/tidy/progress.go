@@ -0,0 +1,58 @@
+package tidy
+
+import "time"
+
+// Progress reports how far along a sort is, see Tidy.OnProgress.
+type Progress struct {
+	// Total is the number of entries the sort is going to move, and TotalBytes
+	// their size. Directories are not counted towards TotalBytes.
+	Total      int   `json:"total"`
+	TotalBytes int64 `json:"total_bytes"`
+
+	// Done is the number of entries handled so far, whether they were moved,
+	// skipped or could not be moved. Bytes is the size of the ones moved.
+	Done  int   `json:"done"`
+	Bytes int64 `json:"bytes"`
+
+	// Current is the name of the entry which was handled last.
+	Current string `json:"current,omitempty"`
+
+	// Elapsed is the time since the first move started, and ETA an estimate of
+	// the time left, based on how long the entries done so far took. ETA is 0
+	// until the first entry is done. Both are in nanoseconds in JSON.
+	Elapsed time.Duration `json:"elapsed"`
+	ETA     time.Duration `json:"eta"`
+}
+
+// planned reports the moves a sort planned, before any of them is carried out.
+func (r *run) planned(moves []*plannedMove) {
+	if r.onProgress == nil {
+		return
+	}
+	r.emitMu.Lock()
+	defer r.emitMu.Unlock()
+	r.progress = Progress{Total: len(moves)}
+	for _, m := range moves {
+		if !m.info.IsDir() {
+			r.progress.TotalBytes += m.info.Size()
+		}
+	}
+	r.progressStarted = time.Now()
+	r.onProgress(r.progress)
+}
+
+// advance reports that the planned move m was handled.
+func (r *run) advance(m *plannedMove) {
+	if r.onProgress == nil {
+		return
+	}
+	r.emitMu.Lock()
+	defer r.emitMu.Unlock()
+	p := &r.progress
+	p.Done++
+	p.Bytes = r.result.Bytes
+	p.Current = m.info.Name()
+	p.Elapsed = time.Since(r.progressStarted)
+	p.ETA = p.Elapsed / time.Duration(p.Done) * time.Duration(p.Total-p.Done)
+	r.onProgress(*p)
+}
@@ -0,0 +1,53 @@
+package tidy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestSortProgress(t *testing.T) {
+	t.Log("Given the need to follow the progress of a sort.")
+
+	testID := 0
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	// fsys is the SortDir, where the files of the test live.
+	fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+	const n = 20
+	for i := 0; i < n; i++ {
+		if err := afero.WriteFile(fsys, fmt.Sprintf("file-%02d.txt", i), []byte("0123456789"), 0o644); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+	}
+	Tidy.Workers = 4
+	var reports []Progress
+	Tidy.OnProgress = func(p Progress) { reports = append(reports, p) }
+
+	t.Logf("\tTest %d:\tWhen sorting with 4 workers.", testID)
+	{
+		if _, err := Tidy.Sort(); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to sort: %v", failed, testID, err)
+		}
+		if len(reports) != n+1 {
+			t.Fatalf("\t%s\tTest %d:\tShould report once planned and once for every file, got %d reports", failed, testID, len(reports))
+		}
+		if first := reports[0]; first.Total != n || first.TotalBytes != 10*n || first.Done != 0 {
+			t.Fatalf("\t%s\tTest %d:\tShould report the planned moves first, got %+v", failed, testID, first)
+		}
+		t.Logf("\t%s\tTest %d:\tShould report the planned moves first.", success, testID)
+
+		for i, p := range reports {
+			if p.Done != i {
+				t.Fatalf("\t%s\tTest %d:\tShould count the files done one at a time, got %+v at report %d", failed, testID, p, i)
+			}
+		}
+		if last := reports[n]; last.Bytes != 10*n || last.ETA != 0 || last.Current == "" {
+			t.Fatalf("\t%s\tTest %d:\tShould report every byte moved at the end, got %+v", failed, testID, last)
+		}
+		t.Logf("\t%s\tTest %d:\tShould count every file and byte moved.", success, testID)
+	}
+}
@@ -0,0 +1,113 @@
+package tidy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// systemDirs are directories of the operating system, and the directories
+// holding the homes of its users, which are never sorted.
+var systemDirs = []string{
+	"/", "/bin", "/boot", "/dev", "/etc", "/home", "/lib", "/lib64", "/opt", "/proc",
+	"/root", "/sbin", "/srv", "/sys", "/tmp", "/usr", "/var",
+	"/Applications", "/Library", "/System", "/Users", "/Volumes", "/private",
+}
+
+// ProtectedPathError is returned when a sort is asked to sort a protected
+// directory, see Tidy.Force.
+type ProtectedPathError struct {
+	Path string
+
+	// Reason tells why the directory is protected.
+	Reason string
+}
+
+func (e *ProtectedPathError) Error() string {
+	return fmt.Sprintf("refusing to sort %s, %s", e.Path, e.Reason)
+}
+
+// checkProtected returns a ProtectedPathError if dir is protected, unless t is
+// forced to sort it. Sorting a protected directory would scatter the files of
+// the system or of the user across category folders. Only dir itself is
+// protected, the directories inside of it can be sorted, except for those of a
+// git repository.
+//
+// The system directories, the home directory and symlinks belong to the OS, so
+// they are only checked when t sorts the filesystem of the OS. There, paths are
+// compared once their symlinks are resolved, so a link to a protected directory
+// is protected as well.
+func (t *Tidy) checkProtected(dir string) error {
+	if t.Force {
+		return nil
+	}
+	dir = filepath.Clean(dir)
+	_, onOS := t.Fs.(*afero.OsFs)
+	resolve := filepath.Clean
+	if onOS {
+		resolve = resolvePath
+	}
+	resolved := resolve(dir)
+	repo := gitRepo(t.Fs, dir)
+	reason := ""
+	switch {
+	case onOS && containsPath(systemDirs, resolved, resolve):
+		reason = "it is a system directory"
+	case onOS && isHomeDir(resolved):
+		reason = "it is the home directory"
+	case containsPath(t.ProtectedPaths, resolved, resolve):
+		reason = "it is listed as a protected path"
+	case isMountRoot(t.Fs, resolved):
+		reason = "it is the root of a mounted filesystem"
+	case repo == dir:
+		reason = "it is a git repository"
+	case repo != "":
+		reason = fmt.Sprintf("it is inside the git repository %s", repo)
+	default:
+		return nil
+	}
+	return &ProtectedPathError{Path: dir, Reason: reason}
+}
+
+// resolvePath returns path with its symlinks resolved, or just cleaned when
+// they can not be, such as when path does not exist.
+func resolvePath(path string) string {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return resolved
+}
+
+// containsPath reports whether paths contains path, once the paths are
+// resolved by resolve.
+func containsPath(paths []string, path string, resolve func(string) string) bool {
+	for _, p := range paths {
+		if resolve(p) == path {
+			return true
+		}
+	}
+	return false
+}
+
+func isHomeDir(dir string) bool {
+	home, err := os.UserHomeDir()
+	return err == nil && resolvePath(home) == dir
+}
+
+// gitRepo returns the root of the git repository dir is in, which is dir or the
+// first of its parents holding a .git entry, or "" when dir is in none.
+func gitRepo(fsys afero.Fs, dir string) string {
+	for {
+		if _, err := fsys.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,133 @@
+package tidy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestProtectedPaths(t *testing.T) {
+	t.Log("Given the need to never sort directories such as the home directory by accident.")
+
+	newTidy := func(testID int) *Tidy {
+		Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewOsFs())
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+		}
+		return Tidy
+	}
+	refused := func(testID int, err error, reason string) {
+		var pe *ProtectedPathError
+		if !errors.As(err, &pe) {
+			t.Fatalf("\t%s\tTest %d:\tShould refuse the directory, got %v", failed, testID, err)
+		}
+		if pe.Reason != reason {
+			t.Fatalf("\t%s\tTest %d:\tShould refuse the directory because %s, got %q", failed, testID, reason, pe.Reason)
+		}
+		t.Logf("\t%s\tTest %d:\tShould refuse the directory because %s.", success, testID, reason)
+	}
+
+	testID := 0
+	t.Logf("\tTest %d:\tWhen sorting a git repository.", testID)
+	{
+		dir := t.TempDir()
+		if err := os.Mkdir(filepath.Join(dir, ".git"), 0o755); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+		Tidy := newTidy(testID)
+		refused(testID, Tidy.ChangeSortDir(dir), "it is a git repository")
+
+		src := filepath.Join(dir, "src")
+		if err := os.Mkdir(src, 0o755); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+		refused(testID, Tidy.ChangeSortDir(src), "it is inside the git repository "+dir)
+
+		Tidy.Force = true
+		if err := Tidy.ChangeSortDir(dir); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould sort the directory when forced: %v", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould sort the directory when forced.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen sorting the home directory as the current directory.", testID)
+	{
+		dir := t.TempDir()
+		t.Setenv("HOME", dir)
+		if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o644); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+		Tidy := newTidy(testID)
+		Tidy.SortDir = dir
+		_, err := Tidy.Sort()
+		refused(testID, err, "it is the home directory")
+
+		if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould leave the files where they are: %v", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould leave the files where they are.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen sorting a directory listed as protected in the config.", testID)
+	{
+		dir := t.TempDir()
+		inside := filepath.Join(dir, "inside")
+		if err := os.Mkdir(inside, 0o755); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+		Tidy := newTidy(testID)
+		cfg := &Config{Protected: []string{dir}}
+		if err := cfg.Apply(Tidy); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to apply the config: %v", failed, testID, err)
+		}
+		refused(testID, Tidy.ChangeSortDir(dir), "it is listed as a protected path")
+
+		if err := Tidy.ChangeSortDir(inside); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould sort the directories inside of it: %v", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould sort the directories inside of it.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen sorting a symlink to the home directory.", testID)
+	{
+		dir := t.TempDir()
+		home := filepath.Join(dir, "home")
+		if err := os.Mkdir(home, 0o755); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+		t.Setenv("HOME", home)
+		link := filepath.Join(dir, "link")
+		if err := os.Symlink(home, link); err != nil {
+			t.Skipf("\t%s\tTest %d:\tShould be able to create a symlink: %v", failed, testID, err)
+		}
+		refused(testID, newTidy(testID).ChangeSortDir(link), "it is the home directory")
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen sorting the root directory.", testID)
+	{
+		refused(testID, newTidy(testID).ChangeSortDir("/"), "it is a system directory")
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen sorting a system directory of a filesystem other than the OS's.", testID)
+	{
+		Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+		}
+		if err := Tidy.Fs.MkdirAll("/tmp", 0o755); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+		if err := Tidy.ChangeSortDir("/tmp"); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould sort the directory: %v", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould sort the directory, as the paths of the OS do not apply.", success, testID)
+	}
+}
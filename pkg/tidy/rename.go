@@ -0,0 +1,113 @@
+package tidy
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// RenameRule normalizes the name of a file as it is sorted.
+type RenameRule string
+
+const (
+	// RenameCollapseWhitespace replaces runs of whitespace with a single space and
+	// trims whitespace from both ends of the name.
+	RenameCollapseWhitespace RenameRule = "collapse-whitespace"
+
+	// RenameStripCopySuffix removes the " (1)" style marker browsers and file
+	// managers add to the end of the names of duplicate files.
+	RenameStripCopySuffix RenameRule = "strip-copy-suffix"
+
+	// RenameLowercaseExt lowercases the extension, so "SCAN.PDF" becomes "SCAN.pdf".
+	RenameLowercaseExt RenameRule = "lowercase-ext"
+
+	// RenameSlugify lowercases the name and replaces everything other than letters
+	// and digits with dashes, so "Scan FINAL.pdf" becomes "scan-final.pdf".
+	RenameSlugify RenameRule = "slugify"
+
+	// RenameDatePrefix prefixes the name with the date the file was last modified,
+	// so "scan.pdf" becomes "2023-04-01-scan.pdf".
+	RenameDatePrefix RenameRule = "date-prefix"
+)
+
+// renameRuleOrder is the order in which rename rules are applied, regardless of
+// the order they are configured in. Copy suffixes have to be stripped before
+// slugify turns their parentheses into dashes, and the date prefix must not be
+// slugified.
+var renameRuleOrder = []RenameRule{
+	RenameCollapseWhitespace,
+	RenameStripCopySuffix,
+	RenameLowercaseExt,
+	RenameSlugify,
+	RenameDatePrefix,
+}
+
+var (
+	whitespaceRegexp = regexp.MustCompile(`\s+`)
+	copySuffixRegexp = regexp.MustCompile(`\s*\(\d+\)$`)
+	slugRegexp       = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+)
+
+// ParseRenameRules converts the names of rename rules, as used in the config,
+// into RenameRules.
+func ParseRenameRules(names []string) ([]RenameRule, error) {
+	rules := make([]RenameRule, 0, len(names))
+	for _, name := range names {
+		rule := RenameRule(strings.TrimSpace(name))
+		if !slices.Contains(renameRuleOrder, rule) {
+			return nil, fmt.Errorf("unknown rename rule %q", name)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// renameFile applies rules to the file name, modTime is the time the file was
+// last modified. Rules which would leave the name empty are skipped.
+func renameFile(rules []RenameRule, name string, modTime time.Time) string {
+	if len(rules) == 0 {
+		return name
+	}
+	enabled := make(map[RenameRule]bool, len(rules))
+	for _, v := range rules {
+		enabled[v] = true
+	}
+
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	if stem == "" {
+		// dotfiles such as ".bashrc" have no stem to work with.
+		return name
+	}
+
+	for _, rule := range renameRuleOrder {
+		if !enabled[rule] {
+			continue
+		}
+		newStem, newExt := stem, ext
+		switch rule {
+		case RenameCollapseWhitespace:
+			newStem = strings.TrimSpace(whitespaceRegexp.ReplaceAllString(stem, " "))
+		case RenameStripCopySuffix:
+			newStem = strings.TrimSpace(copySuffixRegexp.ReplaceAllString(stem, ""))
+		case RenameLowercaseExt:
+			newExt = strings.ToLower(ext)
+		case RenameSlugify:
+			newStem = strings.Trim(slugRegexp.ReplaceAllString(strings.ToLower(stem), "-"), "-")
+			newExt = strings.ToLower(ext)
+		case RenameDatePrefix:
+			date := modTime.Format("2006-01-02")
+			if !strings.HasPrefix(stem, date) {
+				newStem = date + "-" + stem
+			}
+		}
+		if newStem != "" {
+			stem, ext = newStem, newExt
+		}
+	}
+	return stem + ext
+}
@@ -0,0 +1,132 @@
+package tidy
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+type renameScenario struct {
+	testID int
+	rules  []RenameRule
+	name   string
+	want   string
+}
+
+func TestRenameFile(t *testing.T) {
+	t.Log("Given the need to normalize the names of files as they are sorted.")
+
+	modTime := time.Date(2023, time.April, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]renameScenario{
+		"No rules.": {
+			testID: 0,
+			rules:  nil,
+			name:   "Scan 2023-04-01 (3) FINAL.PDF",
+			want:   "Scan 2023-04-01 (3) FINAL.PDF",
+		},
+		"Collapse whitespace.": {
+			testID: 1,
+			rules:  []RenameRule{RenameCollapseWhitespace},
+			name:   "  my   holiday\tphoto .jpg",
+			want:   "my holiday photo.jpg",
+		},
+		"Strip copy suffix and lowercase extension.": {
+			testID: 2,
+			rules:  []RenameRule{RenameLowercaseExt, RenameStripCopySuffix},
+			name:   "Scan 2023-04-01 FINAL (3).PDF",
+			want:   "Scan 2023-04-01 FINAL.pdf",
+		},
+		"Slugify.": {
+			testID: 3,
+			rules:  []RenameRule{RenameStripCopySuffix, RenameSlugify},
+			name:   "Scan 2023-04-01 FINAL (3).PDF",
+			want:   "scan-2023-04-01-final.pdf",
+		},
+		"Date prefix.": {
+			testID: 4,
+			rules:  []RenameRule{RenameSlugify, RenameDatePrefix},
+			name:   "Invoice March.pdf",
+			want:   "2023-04-01-invoice-march.pdf",
+		},
+		"Date prefix is not repeated.": {
+			testID: 5,
+			rules:  []RenameRule{RenameDatePrefix},
+			name:   "2023-04-01-invoice.pdf",
+			want:   "2023-04-01-invoice.pdf",
+		},
+		"Rules never empty the name.": {
+			testID: 6,
+			rules:  []RenameRule{RenameStripCopySuffix},
+			name:   "(1).txt",
+			want:   "(1).txt",
+		},
+		"Only the copy suffix at the end is stripped.": {
+			testID: 7,
+			rules:  []RenameRule{RenameStripCopySuffix},
+			name:   "Report (2023) final (1).txt",
+			want:   "Report (2023) final.txt",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(strconv.Itoa(tc.testID), func(t *testing.T) {
+			t.Logf("\tTest %d:\t%s", tc.testID, name)
+
+			got := renameFile(tc.rules, tc.name, modTime)
+			if got != tc.want {
+				t.Fatalf("\t%s\tTest %d:\tShould have renamed %q to %q, got %q.", failed, tc.testID, tc.name, tc.want, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have renamed %q to %q.", success, tc.testID, tc.name, tc.want)
+		})
+	}
+}
+
+func TestSortRenameConflictAndUndo(t *testing.T) {
+	t.Log("Given the need to rename files as they are sorted, and restore their names on undo.")
+
+	testID := 0
+	files := []string{"Report (1).pdf", "report.pdf"}
+
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	// fsys is the SortDir, where the files of the test live.
+	fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+	Tidy.RenameRules = []RenameRule{RenameStripCopySuffix, RenameSlugify}
+	Tidy.OnConflict = ConflictRename
+
+	for _, v := range files {
+		file, err := fsys.Create(v)
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+		file.Close()
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem.", success, testID)
+
+	if _, err := Tidy.Sort(); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to call Tidy.Sort() without error: %v", failed, testID, err)
+	}
+
+	for _, v := range []string{"PDFs/report.pdf", "PDFs/report-2.pdf"} {
+		if _, err := fsys.Stat(v); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould have renamed a file to %s: %v", failed, testID, v, err)
+		}
+	}
+	t.Logf("\t%s\tTest %d:\tShould have renamed both files without overwriting either.", success, testID)
+
+	if err := Tidy.Undo(); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to call Tidy.Undo() without error: %v", failed, testID, err)
+	}
+	for _, v := range files {
+		if _, err := fsys.Stat(v); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould have restored the original name %q: %v", failed, testID, v, err)
+		}
+	}
+	t.Logf("\t%s\tTest %d:\tShould have restored the original names.", success, testID)
+}
@@ -0,0 +1,123 @@
+package tidy
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Counts totals the entries of one or more sorts by what happened to them.
+type Counts struct {
+	Mkdirs    int   `json:"mkdirs"`
+	Moved     int   `json:"moved"`
+	Bytes     int64 `json:"bytes"`
+	Skipped   int   `json:"skipped"`
+	Conflicts int   `json:"conflicts"`
+	Clashes   int   `json:"clashes"`
+	Errors    int   `json:"errors"`
+}
+
+// add counts e towards the totals.
+func (c *Counts) add(e Event) {
+	switch e.Type {
+	case EventMkdir:
+		c.Mkdirs++
+	case EventMove:
+		c.Moved++
+		c.Bytes += e.Size
+	case EventSkip:
+		c.Skipped++
+	case EventConflict:
+		c.Conflicts++
+	case EventClash:
+		c.Clashes++
+	case EventError:
+		c.Errors++
+	}
+}
+
+// CategoryResult is the number of files and bytes a sort moved into a single
+// category.
+type CategoryResult struct {
+	Name  string `json:"name"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+}
+
+// Result reports what a single sort did. The sizes of directories which were
+// moved as a whole are not counted towards Bytes.
+type Result struct {
+	Run      string    `json:"run"`
+	SortDir  string    `json:"sort_dir"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+
+	// Elapsed is how long the sort took, in nanoseconds in JSON.
+	Elapsed time.Duration `json:"elapsed"`
+
+	Counts
+
+	// Categories holds the files moved into every category, ordered by name.
+	Categories []CategoryResult `json:"categories"`
+
+	// Error is the error the sort failed with, if any.
+	Error string `json:"error,omitempty"`
+
+	// Cancelled reports whether the sort was stopped before it was done, see
+	// Tidy.SortContext.
+	Cancelled bool `json:"cancelled,omitempty"`
+
+	// Remaining is the number of entries the sort was going to move, but left
+	// where they are because it stopped early. Entries the sort had not looked at
+	// yet are counted as well, unless it would have left them alone.
+	Remaining int `json:"remaining,omitempty"`
+
+	// Unchanged is the number of entries the sort left alone without looking at
+	// them, because the previous sort skipped them and they have not changed
+	// since.
+	Unchanged int `json:"unchanged,omitempty"`
+
+	categories map[string]*CategoryResult
+}
+
+func newResult(id, sortDir string) *Result {
+	return &Result{
+		Run:        id,
+		SortDir:    sortDir,
+		Started:    time.Now(),
+		Categories: []CategoryResult{},
+		categories: make(map[string]*CategoryResult),
+	}
+}
+
+// add counts e towards the result.
+func (res *Result) add(e Event) {
+	res.Counts.add(e)
+	if e.Type != EventMove {
+		return
+	}
+	c, ok := res.categories[e.Category]
+	if !ok {
+		c = &CategoryResult{Name: e.Category}
+		res.categories[e.Category] = c
+	}
+	c.Files++
+	c.Bytes += e.Size
+}
+
+// finish completes the result once the sort is done, err is the error the sort
+// failed with, if any.
+func (res *Result) finish(err error) {
+	res.Finished = time.Now()
+	res.Elapsed = res.Finished.Sub(res.Started)
+	if err != nil {
+		res.Error = err.Error()
+		res.Cancelled = errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+	}
+	res.Categories = make([]CategoryResult, 0, len(res.categories))
+	for _, c := range res.categories {
+		res.Categories = append(res.Categories, *c)
+	}
+	sort.Slice(res.Categories, func(i, j int) bool { return res.Categories[i].Name < res.Categories[j].Name })
+}
@@ -0,0 +1,52 @@
+package tidy
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestSortResult(t *testing.T) {
+	t.Log("Given the need to report what a sort did.")
+
+	testID := 0
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	// fsys is the SortDir, where the files of the test live.
+	fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+	files := map[string]string{
+		"cat.jpg":             "meow",
+		"dog.jpg":             "woof!",
+		"story.txt":           "once upon a time",
+		"Documents/story.txt": "the end",
+	}
+	for name, content := range files {
+		if err := afero.WriteFile(fsys, name, []byte(content), 0644); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+	}
+
+	t.Logf("\tTest %d:\tWhen sorting with a conflicting file.", testID)
+	{
+		result, err := Tidy.Sort()
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to sort: %v", failed, testID, err)
+		}
+		if result.Moved != 2 || result.Bytes != 9 || result.Skipped != 1 || result.Conflicts != 1 || result.Errors != 0 {
+			t.Fatalf("\t%s\tTest %d:\tShould count what happened to every file, got %+v", failed, testID, result.Counts)
+		}
+		t.Logf("\t%s\tTest %d:\tShould count what happened to every file.", success, testID)
+
+		if len(result.Categories) != 1 || result.Categories[0] != (CategoryResult{Name: "Images", Files: 2, Bytes: 9}) {
+			t.Fatalf("\t%s\tTest %d:\tShould total the files moved into every category, got %+v", failed, testID, result.Categories)
+		}
+		t.Logf("\t%s\tTest %d:\tShould total the files moved into every category.", success, testID)
+
+		if result.Finished.Before(result.Started) || result.Elapsed != result.Finished.Sub(result.Started) {
+			t.Fatalf("\t%s\tTest %d:\tShould record when the sort ran, got %v to %v", failed, testID, result.Started, result.Finished)
+		}
+		t.Logf("\t%s\tTest %d:\tShould record when the sort ran.", success, testID)
+	}
+}
@@ -0,0 +1,611 @@
+package tidy
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/afero"
+	"golang.org/x/exp/slices"
+)
+
+// Rule routes the files matching a condition into a folder of their own, ahead
+// of the lookup done by the Sorter. Rules are checked in order, and the first
+// one that matches a file decides where it goes.
+//
+// A condition compares properties of a file and combines the comparisons with
+// and, or, not and parentheses, for example:
+//
+//	ext in [pdf] and size > 10MB and name ~ "invoice"
+//
+// The properties that can be compared are:
+//
+//	name   the name of the file. Supports ==, !=, in, ~ and !~ (regular
+//	       expressions) and glob (shell patterns such as "Scan*.pdf")
+//	ext    the extension of the file without the dot, compared without regard
+//	       to case. Supports ==, != and in
+//	size   the size of the file, e.g. 10MB. Units are B, KB, MB, GB and TB,
+//	       where a KB is 1024 bytes. Supports ==, !=, <, <=, > and >=
+//	age    the time since the file was last modified, e.g. 30d. Units are s, m,
+//	       h, d and w. Supports the same operators as size
+//	mime   the MIME type of the file, e.g. "application/pdf", determined by
+//	       its extension or else its content. Supports the same operators as name
+//	owner  the name of the user who owns the file. Supports ==, != and in
+//
+// Rules only apply to files, directories are always left to the Sorter.
+type Rule struct {
+	// Name optionally identifies the rule in logs and explanations.
+	Name string
+
+	// When is the source of the condition of the rule.
+	When string
+
+	// Target is the folder matching files are sorted into. It is either relative
+	// to the destination root, like "Finance/Invoices", or an absolute path.
+	Target string
+
+	cond   condition
+	folder *FiletypeSortingFolder
+}
+
+// CompileRule compiles a rule which sorts the files matching when into target.
+func CompileRule(name, when, target string) (*Rule, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil, fmt.Errorf("rule %q: missing target", when)
+	}
+	cond, err := parseCondition(when)
+	if err != nil {
+		return nil, fmt.Errorf("rule %q: %w", when, err)
+	}
+
+	folder := &FiletypeSortingFolder{Name: filepath.Clean(target)}
+	if dest, err := expandHome(target); err == nil && filepath.IsAbs(dest) {
+		folder = &FiletypeSortingFolder{Name: filepath.Base(dest), Dest: filepath.Clean(dest)}
+	} else if strings.HasPrefix(folder.Name, "..") {
+		return nil, fmt.Errorf("rule %q: target %q is outside of the destination", when, target)
+	}
+
+	return &Rule{Name: name, When: when, Target: target, cond: cond, folder: folder}, nil
+}
+
+// ParseRule compiles a rule written on a single line as "<condition> -> <target>",
+// for example `ext in [pdf] and name ~ "invoice" -> Finance/Invoices`.
+func ParseRule(s string) (*Rule, error) {
+	when, target, err := splitRule(s)
+	if err != nil {
+		return nil, err
+	}
+	return CompileRule("", when, target)
+}
+
+// splitRule splits a rule written on a single line into its condition and its
+// target.
+func splitRule(s string) (when, target string, err error) {
+	i := strings.LastIndex(s, "->")
+	if i == -1 {
+		return "", "", fmt.Errorf("rule %q: expected \"<condition> -> <target>\"", s)
+	}
+	target = strings.TrimSpace(s[i+2:])
+	if unquoted, err := strconv.Unquote(target); err == nil {
+		target = unquoted
+	}
+	return strings.TrimSpace(s[:i]), target, nil
+}
+
+func (rl *Rule) String() string {
+	if rl.Name != "" {
+		return fmt.Sprintf("%s (%s -> %s)", rl.Name, rl.When, rl.Target)
+	}
+	return fmt.Sprintf("%s -> %s", rl.When, rl.Target)
+}
+
+// Match reports whether the file at path, described by info, matches the rule.
+func (rl *Rule) Match(fsys afero.Fs, path string, info fs.FileInfo) (bool, error) {
+	if info.IsDir() {
+		return false, nil
+	}
+	return rl.cond.eval(&fileFacts{fs: fsys, path: path, info: info, now: time.Now()})
+}
+
+// matchRules returns the first rule in rules which matches the file, or nil if
+// none of them do.
+func matchRules(rules []*Rule, fsys afero.Fs, path string, info fs.FileInfo) (*Rule, error) {
+	for _, rl := range rules {
+		ok, err := rl.Match(fsys, path, info)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rl, err)
+		}
+		if ok {
+			return rl, nil
+		}
+	}
+	return nil, nil
+}
+
+// fileFacts holds the properties of a file that conditions are evaluated
+// against. The MIME type and owner are only looked up when a condition needs
+// them.
+type fileFacts struct {
+	fs   afero.Fs
+	path string
+	info fs.FileInfo
+	now  time.Time
+
+	mime  *string
+	owner *string
+}
+
+func (ff *fileFacts) str(field string) (string, error) {
+	switch field {
+	case "name":
+		return ff.info.Name(), nil
+	case "ext":
+		return strings.ToLower(getExtension(ff.info.Name())), nil
+	case "mime":
+		if ff.mime == nil {
+			m, err := detectMIME(ff.fs, ff.path)
+			if err != nil {
+				return "", err
+			}
+			ff.mime = &m
+		}
+		return *ff.mime, nil
+	case "owner":
+		if ff.owner == nil {
+			o := fileOwner(ff.info)
+			ff.owner = &o
+		}
+		return *ff.owner, nil
+	}
+	return "", fmt.Errorf("unknown field %q", field)
+}
+
+func (ff *fileFacts) num(field string) int64 {
+	if field == "age" {
+		return int64(ff.now.Sub(ff.info.ModTime()))
+	}
+	return ff.info.Size()
+}
+
+// detectMIME determines the MIME type of the file at path from its extension,
+// falling back to sniffing its content.
+func detectMIME(fsys afero.Fs, path string) (string, error) {
+	if m := mime.TypeByExtension(filepath.Ext(path)); m != "" {
+		if mediaType, _, err := mime.ParseMediaType(m); err == nil {
+			return mediaType, nil
+		}
+		return m, nil
+	}
+
+	f, err := fsys.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	buf := make([]byte, 512)
+	n, err := f.Read(buf)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(buf[:n]))
+	if err != nil {
+		return "application/octet-stream", nil
+	}
+	return mediaType, nil
+}
+
+// condition is a compiled rule condition.
+type condition interface {
+	eval(ff *fileFacts) (bool, error)
+}
+
+type andCond struct{ left, right condition }
+
+func (c *andCond) eval(ff *fileFacts) (bool, error) {
+	ok, err := c.left.eval(ff)
+	if err != nil || !ok {
+		return false, err
+	}
+	return c.right.eval(ff)
+}
+
+type orCond struct{ left, right condition }
+
+func (c *orCond) eval(ff *fileFacts) (bool, error) {
+	ok, err := c.left.eval(ff)
+	if err != nil || ok {
+		return ok, err
+	}
+	return c.right.eval(ff)
+}
+
+type notCond struct{ cond condition }
+
+func (c *notCond) eval(ff *fileFacts) (bool, error) {
+	ok, err := c.cond.eval(ff)
+	return !ok, err
+}
+
+// compareCond compares a single property of a file against a value.
+type compareCond struct {
+	field string
+	op    string
+
+	str  string
+	strs []string
+	num  int64
+	re   *regexp.Regexp
+}
+
+func (c *compareCond) eval(ff *fileFacts) (bool, error) {
+	if c.field == "size" || c.field == "age" {
+		v := ff.num(c.field)
+		switch c.op {
+		case "==":
+			return v == c.num, nil
+		case "!=":
+			return v != c.num, nil
+		case "<":
+			return v < c.num, nil
+		case "<=":
+			return v <= c.num, nil
+		case ">":
+			return v > c.num, nil
+		default:
+			return v >= c.num, nil
+		}
+	}
+
+	v, err := ff.str(c.field)
+	if err != nil {
+		return false, err
+	}
+	switch c.op {
+	case "==":
+		return c.equal(v, c.str), nil
+	case "!=":
+		return !c.equal(v, c.str), nil
+	case "in":
+		for _, s := range c.strs {
+			if c.equal(v, s) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "~":
+		return c.re.MatchString(v), nil
+	case "!~":
+		return !c.re.MatchString(v), nil
+	default:
+		return path.Match(c.str, v)
+	}
+}
+
+func (c *compareCond) equal(a, b string) bool {
+	if c.field == "ext" {
+		return strings.EqualFold(a, strings.TrimPrefix(b, "."))
+	}
+	return a == b
+}
+
+// fieldOps lists the operators that can be used with each field.
+var fieldOps = map[string][]string{
+	"name":  {"==", "!=", "in", "~", "!~", "glob"},
+	"ext":   {"==", "!=", "in"},
+	"size":  {"==", "!=", "<", "<=", ">", ">="},
+	"age":   {"==", "!=", "<", "<=", ">", ">="},
+	"mime":  {"==", "!=", "in", "~", "!~", "glob"},
+	"owner": {"==", "!=", "in"},
+}
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokString
+	tokOp
+	tokPunct
+	tokEOF
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// lexCondition splits a condition into tokens.
+func lexCondition(s string) ([]token, error) {
+	tokens := make([]token, 0)
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']' || c == ',':
+			tokens = append(tokens, token{kind: tokPunct, text: string(c)})
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(s) && s[end] != c {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			text := s[i+1 : end]
+			if c == '"' {
+				unquoted, err := strconv.Unquote(s[i : end+1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid string %s", s[i:end+1])
+				}
+				text = unquoted
+			}
+			tokens = append(tokens, token{kind: tokString, text: text})
+			i = end + 1
+		case strings.ContainsRune("=!<>~", rune(c)):
+			end := i + 1
+			if end < len(s) && (s[end] == '=' || (c == '!' && s[end] == '~')) {
+				end++
+			}
+			op := s[i:end]
+			if op == "=" || op == "!" {
+				return nil, fmt.Errorf("unknown operator %q", op)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op})
+			i = end
+		default:
+			end := i
+			for end < len(s) && !strings.ContainsRune(" \t\n()[],\"'=!<>~", rune(s[end])) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokWord, text: s[i:end]})
+			i = end
+		}
+	}
+	return append(tokens, token{kind: tokEOF}), nil
+}
+
+// conditionParser is a recursive descent parser for rule conditions:
+//
+//	or         = and { "or" and }
+//	and        = unary { "and" unary }
+//	unary      = "not" unary | "(" or ")" | comparison
+//	comparison = field operator value
+type conditionParser struct {
+	tokens []token
+	pos    int
+}
+
+func parseCondition(s string) (condition, error) {
+	tokens, err := lexCondition(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &conditionParser{tokens: tokens}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q", tok.text)
+	}
+	return cond, nil
+}
+
+func (p *conditionParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *conditionParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *conditionParser) isKeyword(word string) bool {
+	tok := p.peek()
+	return tok.kind == tokWord && strings.EqualFold(tok.text, word)
+}
+
+func (p *conditionParser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orCond{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseAnd() (condition, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andCond{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseUnary() (condition, error) {
+	if p.isKeyword("not") {
+		p.next()
+		cond, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notCond{cond: cond}, nil
+	}
+	if tok := p.peek(); tok.kind == tokPunct && tok.text == "(" {
+		p.next()
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok.kind != tokPunct || tok.text != ")" {
+			return nil, fmt.Errorf("expected ')'")
+		}
+		return cond, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *conditionParser) parseComparison() (condition, error) {
+	tok := p.next()
+	field := strings.ToLower(tok.text)
+	ops, ok := fieldOps[field]
+	if tok.kind != tokWord || !ok {
+		return nil, fmt.Errorf("expected one of name, ext, size, age, mime or owner, got %q", tok.text)
+	}
+
+	opTok := p.next()
+	op := strings.ToLower(opTok.text)
+	if opTok.kind == tokEOF || !slices.Contains(ops, op) {
+		return nil, fmt.Errorf("operator %q can not be used with %s", opTok.text, field)
+	}
+
+	c := &compareCond{field: field, op: op}
+	if op == "in" {
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		c.strs = values
+		return c, nil
+	}
+
+	valTok := p.next()
+	if valTok.kind != tokWord && valTok.kind != tokString {
+		return nil, fmt.Errorf("expected a value after %s %s", field, op)
+	}
+	switch {
+	case field == "size":
+		n, err := parseSize(valTok.text)
+		if err != nil {
+			return nil, err
+		}
+		c.num = n
+	case field == "age":
+		d, err := parseAge(valTok.text)
+		if err != nil {
+			return nil, err
+		}
+		c.num = int64(d)
+	case op == "~" || op == "!~":
+		re, err := regexp.Compile(valTok.text)
+		if err != nil {
+			return nil, err
+		}
+		c.re = re
+	case op == "glob":
+		if _, err := path.Match(valTok.text, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", valTok.text, err)
+		}
+		c.str = valTok.text
+	default:
+		c.str = valTok.text
+	}
+	return c, nil
+}
+
+func (p *conditionParser) parseList() ([]string, error) {
+	if tok := p.next(); tok.kind != tokPunct || tok.text != "[" {
+		return nil, fmt.Errorf("expected '[' after in")
+	}
+	values := make([]string, 0)
+	for {
+		tok := p.next()
+		if tok.kind == tokPunct && tok.text == "]" && len(values) == 0 {
+			return values, nil
+		}
+		if tok.kind != tokWord && tok.kind != tokString {
+			return nil, fmt.Errorf("expected a value in list, got %q", tok.text)
+		}
+		values = append(values, tok.text)
+
+		tok = p.next()
+		if tok.kind == tokPunct && tok.text == "]" {
+			return values, nil
+		}
+		if tok.kind != tokPunct || tok.text != "," {
+			return nil, fmt.Errorf("expected ',' or ']' in list, got %q", tok.text)
+		}
+	}
+}
+
+// parseSize parses a size such as "10MB" into a number of bytes.
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+	upper := strings.ToUpper(s)
+	for _, u := range units {
+		if strings.HasSuffix(upper, u.suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(upper, u.suffix), 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid size %q", s)
+			}
+			return int64(n * float64(u.size)), nil
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n, nil
+}
+
+// parseAge parses an age such as "30d" or "2w" into a duration.
+func parseAge(s string) (time.Duration, error) {
+	i := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+	if i <= 0 {
+		return 0, fmt.Errorf("invalid age %q, expected a number followed by s, m, h, d or w", s)
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	units := map[string]time.Duration{
+		"s": time.Second,
+		"m": time.Minute,
+		"h": time.Hour,
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+	unit, ok := units[strings.ToLower(s[i:])]
+	if !ok {
+		return 0, fmt.Errorf("invalid age %q, expected a number followed by s, m, h, d or w", s)
+	}
+	return time.Duration(n * float64(unit)), nil
+}
@@ -0,0 +1,188 @@
+package tidy
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+type ruleScenario struct {
+	testID  int
+	rule    string
+	file    string
+	want    bool
+	wantErr bool
+}
+
+func TestRuleMatch(t *testing.T) {
+	t.Log("Given the need to route files with declarative rules.")
+
+	fsys := afero.NewMemMapFs()
+	files := map[string]int{
+		"Invoice-2023.pdf": 20 << 10,
+		"notes.TXT":        10,
+		"photo.jpg":        2 << 20,
+	}
+	for name, size := range files {
+		if err := afero.WriteFile(fsys, name, make([]byte, size), 0o644); err != nil {
+			t.Fatalf("\t%s\tShould be able to setup the test filesystem: %v", failed, err)
+		}
+	}
+	old := time.Now().Add(-400 * 24 * time.Hour)
+	if err := fsys.Chtimes("photo.jpg", old, old); err != nil {
+		t.Fatalf("\t%s\tShould be able to setup the test filesystem: %v", failed, err)
+	}
+
+	tests := map[string]ruleScenario{
+		"Extension, size and regular expression.": {
+			testID: 0,
+			rule:   `ext in [pdf] and size > 10KB and name ~ "(?i)invoice" -> Finance/Invoices`,
+			file:   "Invoice-2023.pdf",
+			want:   true,
+		},
+		"Size too small.": {
+			testID: 1,
+			rule:   `ext in [pdf] and size > 1MB -> Finance/Invoices`,
+			file:   "Invoice-2023.pdf",
+			want:   false,
+		},
+		"Extension compared without case.": {
+			testID: 2,
+			rule:   `ext == txt -> Notes`,
+			file:   "notes.TXT",
+			want:   true,
+		},
+		"Glob, or and not.": {
+			testID: 3,
+			rule:   `not (name glob "*.pdf" or name glob "*.TXT") -> Rest`,
+			file:   "photo.jpg",
+			want:   true,
+		},
+		"Age.": {
+			testID: 4,
+			rule:   `age > 52w and mime glob "image/*" -> Archive`,
+			file:   "photo.jpg",
+			want:   true,
+		},
+		"MIME type.": {
+			testID: 5,
+			rule:   `mime == "application/pdf" -> PDFs`,
+			file:   "notes.TXT",
+			want:   false,
+		},
+		"Unknown field.": {
+			testID:  6,
+			rule:    `colour == red -> Red`,
+			wantErr: true,
+		},
+		"Operator not supported by field.": {
+			testID:  7,
+			rule:    `size ~ "10" -> Big`,
+			wantErr: true,
+		},
+		"Missing target.": {
+			testID:  8,
+			rule:    `ext == pdf`,
+			wantErr: true,
+		},
+		"Unbalanced parentheses.": {
+			testID:  9,
+			rule:    `(ext == pdf -> PDFs`,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(strconv.Itoa(tc.testID), func(t *testing.T) {
+			t.Logf("\tTest %d:\t%s", tc.testID, name)
+
+			rule, err := ParseRule(tc.rule)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("\t%s\tTest %d:\tShould have rejected the rule %q.", failed, tc.testID, tc.rule)
+				}
+				t.Logf("\t%s\tTest %d:\tShould have rejected the rule: %v", success, tc.testID, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to compile the rule without error: %v", failed, tc.testID, err)
+			}
+
+			info, err := fsys.Stat(tc.file)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to stat %s: %v", failed, tc.testID, tc.file, err)
+			}
+			got, err := rule.Match(fsys, tc.file, info)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to match the rule without error: %v", failed, tc.testID, err)
+			}
+			if got != tc.want {
+				t.Fatalf("\t%s\tTest %d:\tShould have matched %s: %v, got %v.", failed, tc.testID, tc.file, tc.want, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have matched %s: %v.", success, tc.testID, tc.file, tc.want)
+		})
+	}
+}
+
+func TestSortWithRules(t *testing.T) {
+	t.Log("Given the need to sort files with rules from the config, falling back to the filetype lookup.")
+
+	testID := 0
+	config := `
+rules:
+  - name: invoices
+    when: ext in [pdf] and name ~ "invoice"
+    to: Finance/Invoices
+  - 'name glob "*.log" -> Logs'
+`
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	// fsys is the SortDir, where the files of the test live.
+	fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+	if err := afero.WriteFile(fsys, "config.yaml", []byte(config), 0o644); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to write the config file: %v", failed, testID, err)
+	}
+	cfg, err := LoadConfig(fsys, "config.yaml")
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to load the config: %v", failed, testID, err)
+	}
+	if err := fsys.Remove("config.yaml"); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to remove the config file: %v", failed, testID, err)
+	}
+	if err := cfg.Apply(Tidy); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to apply the config: %v", failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to load rules from the config.", success, testID)
+
+	for _, v := range []string{"invoice-march.pdf", "paper.pdf", "build.log"} {
+		file, err := fsys.Create(v)
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+		file.Close()
+	}
+
+	if _, err := Tidy.Sort(); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to call Tidy.Sort() without error: %v", failed, testID, err)
+	}
+	for _, v := range []string{"Finance/Invoices/invoice-march.pdf", "PDFs/paper.pdf", "Logs/build.log"} {
+		if _, err := fsys.Stat(v); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould have sorted a file to %s: %v", failed, testID, v, err)
+		}
+	}
+	t.Logf("\t%s\tTest %d:\tShould have sorted files by the first matching rule, then by their extension.", success, testID)
+
+	// A second sort must leave the folders created for the rules alone.
+	if _, err := Tidy.Sort(); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to call Tidy.Sort() a second time without error: %v", failed, testID, err)
+	}
+	if _, err := fsys.Stat("Finance/Invoices/invoice-march.pdf"); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould not have moved the folders of the rules: %v", failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould not have moved the folders of the rules.", success, testID)
+}
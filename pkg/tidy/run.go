@@ -0,0 +1,374 @@
+package tidy
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/afero"
+)
+
+// run holds the state of a single Sort or Undo as it is passed through the
+// methods of a Sorter. Every change made to the filesystem goes through the run,
+// so that it is recorded in the journal and can be reverted later.
+//
+// The paths a run works with are absolute, relative paths are relative to the
+// SortDir rather than the working directory, see abs.
+type run struct {
+	id      string
+	ctx     context.Context
+	fs      afero.Fs
+	sortDir string
+
+	// destDir is the root in which the category folders are created, which is
+	// the SortDir unless a destination was chosen.
+	destDir string
+
+	template    *PathTemplate
+	renameRules []RenameRule
+	onConflict  ConflictPolicy
+	onClash     ClashPolicy
+	failFast    bool
+	workers     int
+	rules       []*Rule
+	hooks       []*Hook
+	sinks       []EventSink
+
+	// result collects what the run did from the events it emits, and progress
+	// how far along it is. emitMu guards both, as the workers of a sort emit
+	// events at the same time.
+	emitMu          sync.Mutex
+	result          *Result
+	onProgress      func(p Progress)
+	progress        Progress
+	progressStarted time.Time
+
+	// ignore holds the names of top level entries in the SortDir that must never
+	// be sorted, such as the state directory or a destination inside the SortDir.
+	ignore map[string]bool
+
+	// folders holds the names of the category folders of the Sorter, which are
+	// left alone when they are found in the SortDir.
+	folders map[string]bool
+
+	// folderAlt holds the paths used instead of the category folders which a
+	// file is in the way of, by the name of the folder, see ClashRenameFolder.
+	folderAlt map[string]string
+
+	// dirsFolder and otherFolder are the 'Directories' and 'Other' folders of the
+	// Sorter, looked up once so that classifying an entry does not search for them.
+	dirsFolder, otherFolder *FiletypeSortingFolder
+
+	// dirs holds the directories the run has created or found to exist, so that
+	// moving many files into the same folder only checks for it once. dirsMu
+	// guards it, as the workers of a sort create directories at the same time.
+	dirsMu sync.Mutex
+	dirs   map[string]bool
+
+	// explain is set when the run only explains where files go, and makes
+	// classify note down its reasoning.
+	explain bool
+
+	// index holds the entries the previous sort left where they were, it is nil
+	// when every entry is to be looked at. kept collects the entries this run
+	// leaves where they are, keptMu guards it.
+	index  *index
+	keptMu sync.Mutex
+	kept   []indexEntry
+
+	journal *journal
+	logger  zerolog.Logger
+}
+
+func (t *Tidy) newRun() *run {
+	id := strconv.FormatInt(time.Now().UnixNano(), 36)
+	r := &run{
+		id:          id,
+		ctx:         context.Background(),
+		fs:          t.Fs,
+		sortDir:     t.SortDir,
+		destDir:     t.DestDir,
+		template:    t.Template,
+		renameRules: t.RenameRules,
+		onConflict:  t.OnConflict,
+		onClash:     t.OnClash,
+		failFast:    t.FailFast,
+		workers:     t.Workers,
+		rules:       t.Rules,
+		hooks:       t.Hooks,
+		sinks:       t.Sinks,
+		onProgress:  t.OnProgress,
+		result:      newResult(id, t.SortDir),
+		ignore:      map[string]bool{stateDirName: true},
+		folders:     make(map[string]bool),
+		folderAlt:   make(map[string]string),
+		dirs:        make(map[string]bool),
+		journal:     newJournal(t.Fs, t.SortDir),
+		logger:      t.logger,
+	}
+	if r.destDir == "" {
+		r.destDir = t.SortDir
+	} else {
+		r.destDir = r.abs(r.destDir)
+	}
+	if r.template == nil {
+		r.template = mustParsePathTemplate(DefaultPathTemplate)
+	}
+
+	r.ignoreDest(t.DestDir, t.SortDir)
+	if fts, ok := t.Sorter.(*FiletypeSorter); ok {
+		for _, v := range fts.Dirs {
+			r.folders[v.Name] = true
+			r.ignoreDest(v.Dest, t.SortDir)
+		}
+		r.dirsFolder, r.otherFolder = fts.folder("Directories"), fts.folder("Other")
+	}
+	for _, rule := range t.Rules {
+		r.ignoreDest(r.folderPath(rule.folder), t.SortDir)
+	}
+
+	// Directories created by earlier sorts, such as the ones a path template
+	// creates on demand, are part of the scaffolding as well.
+	pending, err := r.journal.pendingRuns()
+	if err != nil {
+		r.logger.Warn().Err(err).Msg("Could not read the journal.")
+	}
+	for _, jr := range pending {
+		for _, e := range jr.Entries {
+			if e.Op == JournalMkdir {
+				r.ignoreDest(e.Dst, t.SortDir)
+			}
+		}
+	}
+	return r
+}
+
+// abs returns path as an absolute path, a relative path is taken to be relative
+// to the SortDir.
+func (r *run) abs(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(r.sortDir, path)
+}
+
+// rel returns path relative to the SortDir if it lies inside of it, and as an
+// absolute path otherwise. This is how paths are recorded in the journal.
+func (r *run) rel(path string) string {
+	rel, err := filepath.Rel(r.sortDir, r.abs(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return r.abs(path)
+	}
+	return rel
+}
+
+// ignoreDest makes sure that a destination which lives inside of the SortDir is
+// not itself swept up by the sort.
+func (r *run) ignoreDest(dest, sortDir string) {
+	if dest == "" {
+		return
+	}
+	rel := filepath.Clean(dest)
+	if filepath.IsAbs(dest) {
+		var err error
+		if rel, err = filepath.Rel(sortDir, dest); err != nil {
+			return
+		}
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return
+	}
+	r.ignore[strings.Split(rel, string(filepath.Separator))[0]] = true
+}
+
+// folderPath returns the directory in which files belonging to the given folder
+// are placed: either the Dest of the folder, or a directory of the same name in
+// the destination root.
+func (r *run) folderPath(f *FiletypeSortingFolder) string {
+	if alt, ok := r.folderAlt[f.Name]; ok {
+		return alt
+	}
+	if f.Dest != "" {
+		return r.abs(f.Dest)
+	}
+	return filepath.Join(r.destDir, f.Name)
+}
+
+// destination returns the path a file is moved to when it is sorted into the
+// given folder, by applying the rename rules and rendering the path template
+// of the run.
+func (r *run) destination(folder *FiletypeSortingFolder, info fs.FileInfo) (string, error) {
+	root, category := r.destDir, folder.Name
+	if folder.Dest != "" {
+		root, category = r.abs(folder.Dest), ""
+	}
+	if alt, ok := r.folderAlt[folder.Name]; ok {
+		if folder.Dest != "" {
+			root = alt
+		} else {
+			category = filepath.Base(alt)
+		}
+	}
+
+	rel, err := r.template.Execute(TemplateFields{
+		Category: category,
+		Name:     r.targetName(folder, info),
+		ModTime:  info.ModTime(),
+		Size:     info.Size(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, rel), nil
+}
+
+// targetName returns the name a file will have once it is sorted into folder,
+// after the rename rules that apply to it. Directories are never renamed.
+func (r *run) targetName(folder *FiletypeSortingFolder, info fs.FileInfo) string {
+	if info.IsDir() {
+		return info.Name()
+	}
+	rules := r.renameRules
+	if folder.Rename != nil {
+		rules = folder.Rename
+	}
+	return renameFile(rules, info.Name(), info.ModTime())
+}
+
+func (r *run) begin() error {
+	return r.journal.append(JournalEntry{Run: r.id, Op: JournalBegin})
+}
+
+func (r *run) end() error {
+	if err := r.journal.append(JournalEntry{Run: r.id, Op: JournalEnd}); err != nil {
+		r.journal.close()
+		return err
+	}
+	return r.journal.close()
+}
+
+// mkdirAll creates the directory at path along with any missing parents. Every
+// directory that did not exist beforehand is recorded in the journal, so that
+// an Undo can remove it again.
+//
+// Directories are only checked once per run, later calls for the same path
+// return right away.
+func (r *run) mkdirAll(path string) error {
+	path = filepath.Clean(path)
+	r.dirsMu.Lock()
+	known := r.dirs[path]
+	r.dirsMu.Unlock()
+	if known {
+		return nil
+	}
+
+	missing := make([]string, 0)
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if _, err := r.fs.Stat(dir); err == nil {
+			break
+		}
+		missing = append(missing, dir)
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+
+	for i := len(missing) - 1; i >= 0; i-- {
+		created, err := idempotentMkdir(missing[i], os.ModePerm, r.fs)
+		if err != nil {
+			return err
+		}
+		if created {
+			if err := r.journal.append(JournalEntry{Run: r.id, Op: JournalMkdir, Dst: r.rel(missing[i])}); err != nil {
+				return err
+			}
+			r.emit(Event{Type: EventMkdir, Dest: missing[i]})
+		}
+	}
+
+	// path may have existed all along, make sure that it is usable.
+	if _, err := idempotentMkdir(path, os.ModePerm, r.fs); err != nil {
+		return err
+	}
+	r.dirsMu.Lock()
+	r.dirs[path] = true
+	r.dirsMu.Unlock()
+	return nil
+}
+
+// move renames src to dst and records the move in the journal. Any missing
+// parent directories of dst are created first.
+func (r *run) move(src, dst string) error {
+	if err := r.mkdirAll(filepath.Dir(dst)); err != nil {
+		return err
+	}
+	if err := r.fs.Rename(src, dst); err != nil {
+		return err
+	}
+	return r.journal.append(JournalEntry{Run: r.id, Op: JournalMove, Src: r.rel(src), Dst: r.rel(dst)})
+}
+
+// revert undoes every change recorded for jr in the journal, newest first. Files
+// that are already back at their source are left alone, so a revert which was
+// interrupted can safely be run again. A file whose source was taken by
+// something else since is left where it was sorted to, and reported as a
+// conflict.
+//
+// When the context of the run is cancelled, revert stops before the next change
+// and returns the error of the context. The sort is not marked as undone, so the
+// next Undo picks up where this one stopped.
+func (r *run) revert(jr *journalRun) error {
+	for i := len(jr.Entries) - 1; i >= 0; i-- {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		e := jr.Entries[i]
+		src, dst := r.abs(e.Src), r.abs(e.Dst)
+
+		switch e.Op {
+		case JournalMove:
+			_, dstErr := r.fs.Stat(dst)
+			if _, err := r.fs.Stat(src); err == nil {
+				if os.IsNotExist(dstErr) {
+					continue
+				}
+				// something new was put where the file was sorted from, which
+				// the file must not overwrite.
+				r.emit(Event{Type: EventConflict, File: filepath.Base(dst), Dest: src})
+				r.emit(Event{Type: EventSkip, File: filepath.Base(dst), Dest: src, Reason: "something else is at the original path"})
+				continue
+			}
+			if err := r.fs.Rename(dst, src); err != nil {
+				return &SortingError{Filename: filepath.Base(dst), AbsPath: src, Sort: false, Err: err}
+			}
+			r.emit(Event{Type: EventRestore, File: filepath.Base(dst), Dest: src})
+		case JournalMkdir:
+			empty, err := afero.IsEmpty(r.fs, dst)
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return err
+			}
+			if !empty {
+				r.emit(Event{Type: EventSkip, File: filepath.Base(dst), Dest: dst, Reason: "the directory is not empty"})
+				continue
+			}
+			if err := r.fs.Remove(dst); err != nil {
+				return err
+			}
+			r.emit(Event{Type: EventRmdir, Dest: dst})
+		}
+	}
+
+	if err := r.journal.append(JournalEntry{Run: jr.ID, Op: JournalUndone}); err != nil {
+		return err
+	}
+	return r.journal.close()
+}
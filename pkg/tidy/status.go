@@ -0,0 +1,275 @@
+package tidy
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// ScaffoldingState tells how much of the scaffolding of a SortDir exists.
+type ScaffoldingState string
+
+const (
+	// ScaffoldingComplete means every category folder exists.
+	ScaffoldingComplete ScaffoldingState = "complete"
+
+	// ScaffoldingPartial means some of the category folders exist, but not all.
+	ScaffoldingPartial ScaffoldingState = "partial"
+
+	// ScaffoldingMissing means none of the category folders exist.
+	ScaffoldingMissing ScaffoldingState = "missing"
+
+	// ScaffoldingNone means the path template does not group files by their
+	// category, so there are no category folders to create up front.
+	ScaffoldingNone ScaffoldingState = "none"
+)
+
+// RunState tells what is left to do about a sort recorded in the journal.
+type RunState string
+
+const (
+	// RunInterrupted is a sort which was started but never finished.
+	RunInterrupted RunState = "interrupted"
+
+	// RunPartlyUndone is a sort whose undo was interrupted, some of its files
+	// are back where they came from and some are not.
+	RunPartlyUndone RunState = "partly_undone"
+
+	// RunUndoable is a finished sort which can still be undone.
+	RunUndoable RunState = "undoable"
+)
+
+// PendingRun is a sort recorded in the journal which has not been undone.
+type PendingRun struct {
+	Run     string    `json:"run"`
+	Started time.Time `json:"started"`
+	State   RunState  `json:"state"`
+
+	// Moves is the number of moves the journal recorded for the sort.
+	Moves int `json:"moves"`
+}
+
+// MisplacedFile is a file inside of a category folder which the current rules
+// and lookup would sort into a different folder.
+type MisplacedFile struct {
+	Path     string `json:"path"`
+	Category string `json:"category"`
+	Want     string `json:"want"`
+}
+
+// Status reports how untidy a SortDir is, see Tidy.Status.
+type Status struct {
+	SortDir string `json:"sort_dir"`
+
+	// Loose counts the top level entries a sort would move, by the category they
+	// would be moved into, ordered by name.
+	Loose []CategoryResult `json:"loose"`
+
+	// Scaffolding tells how much of the scaffolding exists, and MissingFolders
+	// lists the category folders which do not.
+	Scaffolding    ScaffoldingState `json:"scaffolding"`
+	MissingFolders []string         `json:"missing_folders"`
+
+	// Misplaced lists the files which sit in the wrong category folder.
+	Misplaced []MisplacedFile `json:"misplaced"`
+
+	// Clashes lists the files in the way of the category folders, and what a
+	// sort does about them.
+	Clashes []FolderClash `json:"clashes"`
+
+	// Pending lists the sorts in the journal which have not been undone yet,
+	// oldest first.
+	Pending []PendingRun `json:"pending"`
+}
+
+// Clean reports whether there is nothing for a sort to do: no loose entries, no
+// misplaced files, no missing scaffolding and no interrupted sort or undo.
+func (s *Status) Clean() bool {
+	if len(s.Loose) > 0 || len(s.Misplaced) > 0 || len(s.MissingFolders) > 0 || len(s.Clashes) > 0 {
+		return false
+	}
+	for _, p := range s.Pending {
+		if p.State != RunUndoable {
+			return false
+		}
+	}
+	return true
+}
+
+// Status reports how untidy the SortDir is: the loose entries a sort would move
+// by their category, whether the scaffolding is missing or only partly there,
+// the files which sit in the wrong category folder according to the current
+// rules, and the sorts in the journal which were interrupted or can be undone.
+//
+// Status does not change anything on the filesystem.
+func (t *Tidy) Status() (*Status, error) {
+	r := t.newRun()
+	s := &Status{
+		SortDir:        t.SortDir,
+		Loose:          []CategoryResult{},
+		MissingFolders: []string{},
+		Misplaced:      []MisplacedFile{},
+		Pending:        []PendingRun{},
+	}
+
+	// the clashes come first, as a file left in the way of a folder is not
+	// loose.
+	clashes, err := t.Sorter.clashes(r)
+	if err != nil {
+		return nil, err
+	}
+	s.Clashes = append([]FolderClash{}, clashes...)
+
+	entries, err := afero.ReadDir(r.fs, r.sortDir)
+	if err != nil {
+		return nil, err
+	}
+	loose := make(map[string]*CategoryResult)
+	for _, info := range entries {
+		path := filepath.Join(r.sortDir, info.Name())
+		c, err := t.Sorter.classify(r, path, info)
+		if err != nil {
+			return nil, &SortingError{Filename: info.Name(), AbsPath: path, Sort: true, Err: err}
+		}
+		if c.folder == nil {
+			continue
+		}
+		cr, ok := loose[c.folder.Name]
+		if !ok {
+			cr = &CategoryResult{Name: c.folder.Name}
+			loose[c.folder.Name] = cr
+		}
+		cr.Files++
+		if !info.IsDir() {
+			cr.Bytes += info.Size()
+		}
+	}
+	for _, cr := range loose {
+		s.Loose = append(s.Loose, *cr)
+	}
+	sort.Slice(s.Loose, func(i, j int) bool { return s.Loose[i].Name < s.Loose[j].Name })
+
+	if err := t.Sorter.inspect(r, s); err != nil {
+		return nil, err
+	}
+
+	runs, err := r.journal.pendingRuns()
+	if err != nil {
+		return nil, err
+	}
+	for _, jr := range runs {
+		if p, ok := r.pending(jr); ok {
+			s.Pending = append(s.Pending, p)
+		}
+	}
+	return s, nil
+}
+
+// pending describes a sort of the journal which has not been undone. Sorts which
+// finished without changing anything are not pending, as there is nothing to
+// undo.
+func (r *run) pending(jr *journalRun) (PendingRun, bool) {
+	p := PendingRun{Run: jr.ID, Started: jr.Started, State: RunUndoable}
+	reverted := 0
+	for _, e := range jr.Entries {
+		if e.Op != JournalMove {
+			continue
+		}
+		p.Moves++
+		if _, err := r.fs.Stat(r.abs(e.Dst)); os.IsNotExist(err) {
+			if _, err := r.fs.Stat(r.abs(e.Src)); err == nil {
+				reverted++
+			}
+		}
+	}
+
+	switch {
+	case !jr.Finished:
+		p.State = RunInterrupted
+	case reverted > 0:
+		p.State = RunPartlyUndone
+	case len(jr.Entries) == 0:
+		return p, false
+	}
+	return p, true
+}
+
+// inspect checks the scaffolding of the run, and looks for files in the category
+// folders which classify would sort into a different folder. Folders with their
+// own Dest are not searched, as they are usually shared with other programs.
+func (fts *FiletypeSorter) inspect(r *run, s *Status) error {
+	if !r.template.groupsByCategory() {
+		s.Scaffolding = ScaffoldingNone
+		return nil
+	}
+
+	// the folders which a file is in the way of are created once the file is
+	// moved, they are missing but reported as clashes.
+	clashed := make(map[string]bool)
+	for _, fc := range s.Clashes {
+		if fc.Policy != ClashRenameFolder {
+			clashed[fc.Category] = true
+		}
+	}
+	missing := 0
+	for _, folder := range fts.Dirs {
+		path := r.folderPath(folder)
+		info, err := r.fs.Stat(path)
+		if err != nil || !info.IsDir() {
+			if !clashed[folder.Name] {
+				s.MissingFolders = append(s.MissingFolders, path)
+			}
+			missing++
+			continue
+		}
+		if folder.Dest != "" {
+			continue
+		}
+		if err := fts.inspectFolder(r, s, folder, path); err != nil {
+			return err
+		}
+	}
+
+	switch missing {
+	case 0:
+		s.Scaffolding = ScaffoldingComplete
+	case len(fts.Dirs):
+		s.Scaffolding = ScaffoldingMissing
+	default:
+		s.Scaffolding = ScaffoldingPartial
+	}
+	return nil
+}
+
+// inspectFolder adds the files in the category folder at root which belong in a
+// different folder to s. The directories in the Directories folder were sorted
+// as a whole, so they are not looked into.
+func (fts *FiletypeSorter) inspectFolder(r *run, s *Status, folder *FiletypeSortingFolder, root string) error {
+	return afero.Walk(r.fs, root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		if info.IsDir() {
+			if folder.Name == "Directories" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		c, err := fts.classify(r, path, info)
+		if err != nil {
+			return &SortingError{Filename: info.Name(), AbsPath: path, Sort: true, Err: err}
+		}
+		if c.folder != nil && c.folder.Name != folder.Name {
+			s.Misplaced = append(s.Misplaced, MisplacedFile{Path: path, Category: folder.Name, Want: c.folder.Name})
+		}
+		return nil
+	})
+}
@@ -0,0 +1,91 @@
+package tidy
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestStatus(t *testing.T) {
+	t.Log("Given the need to check how untidy a directory is before sorting it.")
+
+	testID := 0
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	// fsys is the SortDir, where the files of the test live.
+	fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+	for _, v := range []string{"Images", "Documents"} {
+		if err := fsys.Mkdir(v, 0755); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+	}
+	for name, content := range map[string]string{"cat.jpg": "meow", "dog.png": "woof", "story.txt": "once", "Documents/song.mp3": "la la", "Images/photo.jpg": "cheese"} {
+		if err := afero.WriteFile(fsys, name, []byte(content), 0644); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+	}
+
+	t.Logf("\tTest %d:\tWhen checking a directory which was never sorted.", testID)
+	{
+		status, err := Tidy.Status()
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to check the status: %v", failed, testID, err)
+		}
+		want := []CategoryResult{{Name: "Documents", Files: 1, Bytes: 4}, {Name: "Images", Files: 2, Bytes: 8}}
+		if len(status.Loose) != len(want) || status.Loose[0] != want[0] || status.Loose[1] != want[1] {
+			t.Fatalf("\t%s\tTest %d:\tShould count the loose files by category, got %+v", failed, testID, status.Loose)
+		}
+		t.Logf("\t%s\tTest %d:\tShould count the loose files by category.", success, testID)
+
+		if status.Scaffolding != ScaffoldingPartial || len(status.MissingFolders) != len(Tidy.Sorter.(*FiletypeSorter).Dirs)-2 {
+			t.Fatalf("\t%s\tTest %d:\tShould report the scaffolding as partly present, got %s with %v missing", failed, testID, status.Scaffolding, status.MissingFolders)
+		}
+		t.Logf("\t%s\tTest %d:\tShould report the scaffolding as partly present.", success, testID)
+
+		if len(status.Misplaced) != 1 || status.Misplaced[0].Category != "Documents" || status.Misplaced[0].Want != "Audio" {
+			t.Fatalf("\t%s\tTest %d:\tShould flag the file in the wrong folder, got %+v", failed, testID, status.Misplaced)
+		}
+		t.Logf("\t%s\tTest %d:\tShould flag the file in the wrong folder.", success, testID)
+
+		if len(status.Pending) != 0 || status.Clean() {
+			t.Fatalf("\t%s\tTest %d:\tShould report nothing pending, and the directory as untidy, got %+v", failed, testID, status.Pending)
+		}
+		t.Logf("\t%s\tTest %d:\tShould report nothing pending in the journal.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen checking a directory after sorting it.", testID)
+	{
+		if _, err := Tidy.Sort(); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to sort: %v", failed, testID, err)
+		}
+		status, err := Tidy.Status()
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to check the status: %v", failed, testID, err)
+		}
+		if len(status.Loose) != 0 || status.Scaffolding != ScaffoldingComplete {
+			t.Fatalf("\t%s\tTest %d:\tShould report no loose files and complete scaffolding, got %+v and %s", failed, testID, status.Loose, status.Scaffolding)
+		}
+		t.Logf("\t%s\tTest %d:\tShould report no loose files and complete scaffolding.", success, testID)
+
+		if len(status.Pending) != 1 || status.Pending[0].State != RunUndoable || status.Pending[0].Moves != 3 {
+			t.Fatalf("\t%s\tTest %d:\tShould report the sort as undoable, got %+v", failed, testID, status.Pending)
+		}
+		t.Logf("\t%s\tTest %d:\tShould report the sort as undoable.", success, testID)
+
+		// bring back a single file, as an interrupted undo would.
+		if err := fsys.Rename("Images/cat.jpg", "cat.jpg"); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to move the file back: %v", failed, testID, err)
+		}
+		status, err = Tidy.Status()
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to check the status: %v", failed, testID, err)
+		}
+		if len(status.Pending) != 1 || status.Pending[0].State != RunPartlyUndone {
+			t.Fatalf("\t%s\tTest %d:\tShould report the sort as partly undone, got %+v", failed, testID, status.Pending)
+		}
+		t.Logf("\t%s\tTest %d:\tShould report an interrupted undo.", success, testID)
+	}
+}
@@ -0,0 +1,212 @@
+package tidy
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// DefaultPathTemplate places every file directly inside the folder of its
+// category, which is how tidy has always sorted.
+const DefaultPathTemplate = "{category}/{name}"
+
+// PathTemplate describes where a file is placed, relative to the destination
+// root. Fields are written in braces and may be followed by filters, for example
+// "{category}/{year}/{month}/{stem}{ext}" or "{category}/{ext|upper}/{name}".
+//
+// The available fields are:
+//
+//	category  the name of the category the file was sorted into
+//	name      the full name of the file
+//	stem      the name of the file without its extension
+//	ext       the extension of the file including the dot, e.g. ".pdf"
+//	year      the year the file was last modified, e.g. "2023"
+//	month     the month the file was last modified, e.g. "04"
+//	day       the day of the month the file was last modified, e.g. "01"
+//	date      the date the file was last modified, e.g. "2023-04-01"
+//	size      the size bucket of the file: empty, tiny, small, medium, large or huge
+//
+// and the available filters are upper and lower.
+//
+// A path segment never starts with the dot of an extension, so "{ext|upper}/"
+// renders as "PDF/" while "{stem}{ext}" renders as "report.pdf". When a category
+// has a destination of its own, {category} renders as an empty string, since
+// that destination already is the folder of the category.
+type PathTemplate struct {
+	raw   string
+	parts []templatePart
+}
+
+// templatePart is either a literal piece of text or a field with its filters.
+type templatePart struct {
+	literal string
+	field   string
+	filters []string
+}
+
+// TemplateFields holds the information about a file that a PathTemplate can
+// refer to.
+type TemplateFields struct {
+	Category string
+	Name     string
+	ModTime  time.Time
+	Size     int64
+}
+
+var templateFilters = map[string]func(string) string{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+var templateFieldNames = []string{"category", "name", "stem", "ext", "year", "month", "day", "date", "size"}
+
+// ParsePathTemplate parses s into a PathTemplate. An error is returned if s
+// refers to unknown fields or filters, or if its final path segment does not
+// contain the name of the file.
+func ParsePathTemplate(s string) (*PathTemplate, error) {
+	pt := &PathTemplate{raw: s}
+
+	rest := s
+	for rest != "" {
+		open := strings.IndexByte(rest, '{')
+		if open == -1 {
+			pt.parts = append(pt.parts, templatePart{literal: rest})
+			break
+		}
+		if open > 0 {
+			pt.parts = append(pt.parts, templatePart{literal: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end == -1 {
+			return nil, fmt.Errorf("template %q: unclosed '{'", s)
+		}
+		expr := strings.Split(rest[open+1:open+end], "|")
+		part := templatePart{field: strings.TrimSpace(expr[0])}
+		if !slices.Contains(templateFieldNames, part.field) {
+			return nil, fmt.Errorf("template %q: unknown field %q", s, part.field)
+		}
+		for _, filter := range expr[1:] {
+			filter = strings.TrimSpace(filter)
+			if _, ok := templateFilters[filter]; !ok {
+				return nil, fmt.Errorf("template %q: unknown filter %q", s, filter)
+			}
+			part.filters = append(part.filters, filter)
+		}
+		pt.parts = append(pt.parts, part)
+		rest = rest[open+end+1:]
+	}
+
+	if !pt.namesFile() {
+		return nil, fmt.Errorf("template %q: the last path segment must contain {name} or {stem}", s)
+	}
+	return pt, nil
+}
+
+func mustParsePathTemplate(s string) *PathTemplate {
+	pt, err := ParsePathTemplate(s)
+	if err != nil {
+		panic(err)
+	}
+	return pt
+}
+
+// namesFile reports whether the final path segment of the template refers to the
+// name of the file, without which every file would render to the same path.
+func (pt *PathTemplate) namesFile() bool {
+	for i := len(pt.parts) - 1; i >= 0; i-- {
+		p := pt.parts[i]
+		if p.field == "" && strings.ContainsRune(p.literal, '/') {
+			return false
+		}
+		if p.field == "name" || p.field == "stem" {
+			return true
+		}
+	}
+	return false
+}
+
+// groupsByCategory reports whether the template places files in a folder named
+// after their category, which means the category folders can be created up
+// front as scaffolding.
+func (pt *PathTemplate) groupsByCategory() bool {
+	return len(pt.parts) > 1 &&
+		pt.parts[0].field == "category" && len(pt.parts[0].filters) == 0 &&
+		strings.HasPrefix(pt.parts[1].literal, "/")
+}
+
+func (pt *PathTemplate) String() string {
+	return pt.raw
+}
+
+// Execute renders the template for a single file. The returned path is relative,
+// and is guaranteed not to escape the directory it is joined with.
+func (pt *PathTemplate) Execute(fields TemplateFields) (string, error) {
+	var b strings.Builder
+	for _, p := range pt.parts {
+		if p.field == "" {
+			b.WriteString(p.literal)
+			continue
+		}
+		v := fields.value(p.field)
+		if p.field == "ext" && (b.Len() == 0 || strings.HasSuffix(b.String(), "/")) {
+			v = strings.TrimPrefix(v, ".")
+		}
+		for _, filter := range p.filters {
+			v = templateFilters[filter](v)
+		}
+		b.WriteString(v)
+	}
+
+	path := filepath.Clean(filepath.FromSlash(strings.TrimLeft(b.String(), "/")))
+	if path == "." || path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("template %q renders %q for %s, which is not a path inside of the destination", pt.raw, path, fields.Name)
+	}
+	return path, nil
+}
+
+// value renders a single field of a template. Only the fields a template uses are
+// rendered, as Execute runs for every file of a sort.
+func (fields TemplateFields) value(field string) string {
+	switch field {
+	case "category":
+		return fields.Category
+	case "name":
+		return fields.Name
+	case "stem":
+		return strings.TrimSuffix(fields.Name, filepath.Ext(fields.Name))
+	case "ext":
+		return filepath.Ext(fields.Name)
+	case "year":
+		return fields.ModTime.Format("2006")
+	case "month":
+		return fields.ModTime.Format("01")
+	case "day":
+		return fields.ModTime.Format("02")
+	case "date":
+		return fields.ModTime.Format("2006-01-02")
+	case "size":
+		return sizeBucket(fields.Size)
+	}
+	return ""
+}
+
+// sizeBucket groups a file size into a handful of coarse, human readable sizes.
+func sizeBucket(size int64) string {
+	switch {
+	case size == 0:
+		return "empty"
+	case size < 100<<10:
+		return "tiny"
+	case size < 1<<20:
+		return "small"
+	case size < 100<<20:
+		return "medium"
+	case size < 1<<30:
+		return "large"
+	default:
+		return "huge"
+	}
+}
@@ -0,0 +1,103 @@
+package tidy
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+type templateScenario struct {
+	testID   int
+	template string
+	fields   TemplateFields
+	want     string
+	wantErr  bool
+}
+
+func TestPathTemplate(t *testing.T) {
+	t.Log("Given the need to describe the destination of a file with a template.")
+
+	modTime := time.Date(2023, time.April, 1, 12, 0, 0, 0, time.UTC)
+	report := TemplateFields{Category: "PDFs", Name: "report.pdf", ModTime: modTime, Size: 2 << 20}
+
+	tests := map[string]templateScenario{
+		"Default template.": {
+			testID:   0,
+			template: DefaultPathTemplate,
+			fields:   report,
+			want:     "PDFs/report.pdf",
+		},
+		"Dated folders.": {
+			testID:   1,
+			template: "{category}/{year}/{month}/{stem}{ext}",
+			fields:   report,
+			want:     "PDFs/2023/04/report.pdf",
+		},
+		"Extension as a folder name.": {
+			testID:   2,
+			template: "{category}/{ext|upper}/{name}",
+			fields:   report,
+			want:     "PDFs/PDF/report.pdf",
+		},
+		"Size bucket and date.": {
+			testID:   3,
+			template: "{size}/{date}-{name|lower}",
+			fields:   TemplateFields{Category: "Images", Name: "IMG.JPG", ModTime: modTime, Size: 10},
+			want:     "tiny/2023-04-01-img.jpg",
+		},
+		"Category with a destination of its own.": {
+			testID:   4,
+			template: "{category}/{year}/{name}",
+			fields:   TemplateFields{Category: "", Name: "cat.jpg", ModTime: modTime},
+			want:     "2023/cat.jpg",
+		},
+		"Unknown field.": {
+			testID:   5,
+			template: "{category}/{colour}/{name}",
+			wantErr:  true,
+		},
+		"Unknown filter.": {
+			testID:   6,
+			template: "{category}/{name|reverse}",
+			wantErr:  true,
+		},
+		"File name missing from the final segment.": {
+			testID:   7,
+			template: "{name}/{category}",
+			wantErr:  true,
+		},
+		"Template escaping the destination.": {
+			testID:   8,
+			template: "../{name}",
+			fields:   report,
+			wantErr:  true,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(strconv.Itoa(tc.testID), func(t *testing.T) {
+			t.Logf("\tTest %d:\t%s", tc.testID, name)
+
+			pt, err := ParsePathTemplate(tc.template)
+			if err == nil {
+				var got string
+				got, err = pt.Execute(tc.fields)
+				if err == nil && got != tc.want {
+					t.Fatalf("\t%s\tTest %d:\tShould have rendered %q, got %q.", failed, tc.testID, tc.want, got)
+				}
+			}
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("\t%s\tTest %d:\tShould have rejected the template %q.", failed, tc.testID, tc.template)
+				}
+				t.Logf("\t%s\tTest %d:\tShould have rejected the template: %v", success, tc.testID, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to render the template without error: %v", failed, tc.testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have rendered %q.", success, tc.testID, tc.want)
+		})
+	}
+}
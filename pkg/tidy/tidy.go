@@ -1,18 +1,20 @@
 package tidy
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/duexcoast/tidy-up/pkg/logger"
 	"github.com/rs/zerolog"
 	"github.com/spf13/afero"
-	"golang.org/x/exp/slices"
 )
 
 // Tidy is used to execute the sorting of a chosen directory. The Sorter field
@@ -32,6 +34,75 @@ type Tidy struct {
 	// The directory which the Sort() and Unsort() methods will execute upon.
 	SortDir string
 
+	// The root directory in which the sorting folders are created. When empty, the
+	// folders are created inside of SortDir. A relative DestDir is relative to the
+	// SortDir.
+	DestDir string
+
+	// Template determines where each file is placed relative to DestDir, see
+	// PathTemplate for the fields it can use. Defaults to DefaultPathTemplate.
+	Template *PathTemplate
+
+	// RenameRules are applied to the name of every file as it is sorted, unless
+	// its folder has rename rules of its own.
+	RenameRules []RenameRule
+
+	// Rules are checked in order against every file before the Sorter decides
+	// where it goes. The first rule that matches a file sorts it into its target.
+	Rules []*Rule
+
+	// OnConflict decides what happens when a file already exists at the
+	// destination of a file. Defaults to ConflictSkip.
+	OnConflict ConflictPolicy
+
+	// OnClash decides what happens when a file is in the way of a sorting
+	// folder, such as a file called "Images". Defaults to ClashSort.
+	OnClash ClashPolicy
+
+	// Workers is the number of moves a sort carries out at the same time, which
+	// speeds up sorting on slow filesystems. Moves which can conflict with each
+	// other are never carried out at the same time. Values below 2 sort one
+	// entry at a time.
+	Workers int
+
+	// Full makes a sort look at every entry of the SortDir. By default, a sort
+	// leaves the entries which the previous sort left where they were alone, as
+	// long as they have not changed since, see the index in the state directory.
+	Full bool
+
+	// FailFast stops a sort at the first entry it cannot move. By default, a sort
+	// moves every entry it can, and returns the ones it could not as
+	// SortingErrors.
+	FailFast bool
+
+	// Force sorts the SortDir even if it is protected, such as the home
+	// directory, a system directory or a git repository. See ProtectedPaths.
+	Force bool
+
+	// LockWait is how long a sort or an undo waits for another one to finish
+	// with the SortDir, which it locks for as long as it runs. By default it
+	// fails right away with a LockedError, a negative LockWait waits for as long
+	// as it takes.
+	LockWait time.Duration
+
+	// ProtectedPaths are more directories which are never sorted, unless Force
+	// is set. They are absolute paths.
+	ProtectedPaths []string
+
+	// Hooks are commands run before and after files are moved, and after every
+	// sort, see Hook.
+	Hooks []*Hook
+
+	// Sinks receive an Event for every sort that starts or finishes, every move
+	// and every error. Call Close once done sorting to flush them. NewTidy adds
+	// a LogObserver, which logs the events.
+	Sinks []EventSink
+
+	// OnProgress is called as a sort makes progress: once every entry is planned,
+	// and again after every entry is handled. It is called by one worker at a
+	// time, which the other workers wait for, so it should return quickly.
+	OnProgress func(p Progress)
+
 	Flags *TidyFlags
 
 	logger zerolog.Logger
@@ -49,19 +120,32 @@ func NewTidy(sorter Sorter, flags *TidyFlags, fsys afero.Fs) (*Tidy, error) {
 		l.Err(err)
 		return nil, err
 	}
+	l := logger.Get()
 	return &Tidy{
-		Sorter:  sorter,
-		Fs:      fsys,
-		SortDir: wd,
-		Flags:   flags,
-		logger:  logger.Get(),
+		Sorter:     sorter,
+		Fs:         fsys,
+		SortDir:    wd,
+		Template:   mustParsePathTemplate(DefaultPathTemplate),
+		OnConflict: ConflictSkip,
+		Sinks:      []EventSink{ObserverSink(NewLogObserver(l))},
+		Flags:      flags,
+		logger:     l,
 	}, nil
 }
 
 // ChangeSortDir checks if the argument provided as a string is a directory, if it
-// is, then it changes the current working directory to the provided string.
+// is, then it becomes the SortDir. A leading "~" is expanded to the home
+// directory of the user, and relative paths are resolved against the current
+// working directory.
+//
+// The working directory of the process is never changed, so several Tidy values
+// can sort different directories at the same time.
 func (t *Tidy) ChangeSortDir(path string) error {
 	// cleanPath := filepath.Clean(path)
+	path, err := expandHome(path)
+	if err != nil {
+		return err
+	}
 	info, err := t.Fs.Stat(path)
 	if err != nil {
 		t.logger.Err(err).Msg("Could not ")
@@ -69,49 +153,243 @@ func (t *Tidy) ChangeSortDir(path string) error {
 	}
 
 	if info.IsDir() {
-		t.SortDir = path
-		err := os.Chdir(path)
-		if err != nil {
+		path = absPath(path)
+		if err := t.checkProtected(path); err != nil {
 			return err
 		}
+		t.SortDir = path
 		return nil
 	}
 	return errors.New("the string passed is not a directory.")
 
 }
 
+// ChangeDestDir sets the root directory in which the sorting folders will be
+// created. A leading "~" is expanded to the home directory of the user, and
+// relative paths are resolved against the current working directory. The
+// directory does not need to exist yet, it is created along with the
+// scaffolding.
+func (t *Tidy) ChangeDestDir(path string) error {
+	path, err := expandHome(path)
+	if err != nil {
+		return err
+	}
+	info, err := t.Fs.Stat(path)
+	if err == nil && !info.IsDir() {
+		return errors.New("the destination passed is not a directory.")
+	}
+	t.DestDir = absPath(path)
+	return nil
+}
+
 // CreateScaffolding() creates the given scaffolding for the directory
 // based upon the Sorter type.
 func (t *Tidy) CreateScaffolding() error {
-	if err := t.Sorter.createScaffolding(t.Fs); err != nil {
+	if err := t.checkProtected(t.SortDir); err != nil {
 		return err
 	}
-	return nil
+	lock, err := t.lock(context.Background())
+	if err != nil {
+		return err
+	}
+	defer t.unlock(lock)
+	r := t.newRun()
+	if err := r.begin(); err != nil {
+		return err
+	}
+	if err := t.Sorter.createScaffolding(r); err != nil {
+		r.end()
+		return err
+	}
+	return r.end()
 }
 
 // Sort will create the necessary scaffolding, then sort the directory specified by
 // t.SortDir.Sort is a wrapper around the t.Sorter.sort() method, so changing the
 // t.Sorter will determine how the directory is sorted.
-func (t *Tidy) Sort() error {
-	if err := t.CreateScaffolding(); err != nil {
+//
+// Every directory created and file moved is recorded in a journal kept in the
+// SortDir, which is what Undo uses to bring the files back. The returned Result
+// reports what the sort did, and is returned even if the sort failed part way.
+//
+// Entries which cannot be moved are skipped, and returned as SortingErrors once
+// every other entry is sorted, unless FailFast is set.
+//
+// A protected SortDir, such as the home directory, is refused with a
+// ProtectedPathError unless Force is set. The SortDir is locked while it is
+// sorted, see LockWait.
+func (t *Tidy) Sort() (*Result, error) {
+	return t.SortContext(context.Background())
+}
+
+// SortContext sorts the SortDir like Sort, and stops once ctx is cancelled. The
+// moves which already started are finished first, so every file is either where
+// it was or in its folder, and the journal records every move that was made. The
+// sort is then closed in the journal, so that Undo can revert it as usual.
+//
+// A cancelled sort returns the error of ctx, along with a Result which is marked
+// Cancelled and counts the entries which were left where they are.
+func (t *Tidy) SortContext(ctx context.Context) (*Result, error) {
+	if err := t.checkProtected(t.SortDir); err != nil {
+		return nil, err
+	}
+	lock, err := t.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer t.unlock(lock)
+	r := t.newRun()
+	r.ctx = ctx
+	config := t.fingerprint()
+	if !t.Full {
+		ix, err := loadIndex(t.Fs, t.SortDir, config)
+		if err != nil {
+			r.logger.Warn().Err(err).Msg("Could not read the index, looking at every entry.")
+		}
+		r.index = ix
+	}
+	if err := r.begin(); err != nil {
+		return nil, err
+	}
+	r.emit(Event{Type: EventRunStarted})
+	if err := t.Sorter.createScaffolding(r); err != nil {
+		r.end()
+		r.finish(err)
+		return r.result, err
+	}
+	err = t.Sorter.sort(r)
+	r.saveIndex(config)
+	if err != nil {
+		r.end()
+		r.finish(err)
+		return r.result, err
+	}
+	if err := r.end(); err != nil {
+		r.finish(err)
+		return r.result, err
+	}
+	r.finish(nil)
+	r.afterRun()
+	return r.result, nil
+}
+
+// SortFile sorts a single entry of the SortDir, given by its name, in the same way
+// Sort would. The scaffolding is created first if it is missing. Entries which
+// Sort would leave alone, such as the scaffolding itself, are not touched.
+func (t *Tidy) SortFile(name string) error {
+	if err := t.checkProtected(t.SortDir); err != nil {
+		return err
+	}
+	lock, err := t.lock(context.Background())
+	if err != nil {
+		return err
+	}
+	defer t.unlock(lock)
+	r := t.newRun()
+	path := r.abs(name)
+	info, err := t.Fs.Stat(path)
+	if err != nil {
+		return err
+	}
+	c, err := t.Sorter.classify(r, path, info)
+	if err != nil {
 		return err
 	}
-	// TODO: Where should I check for errors.Is(SortingError), and how should I
-	// log the error?
-	if err := t.Sorter.sort(t.Fs); err != nil {
+	if c.folder == nil {
+		return nil
+	}
+
+	if err := r.begin(); err != nil {
 		return err
 	}
+	r.emit(Event{Type: EventRunStarted})
+	if err := t.Sorter.createScaffolding(r); err != nil {
+		r.end()
+		r.finish(err)
+		return err
+	}
+	if err := t.Sorter.sortEntry(r, path, info); err != nil {
+		r.end()
+		r.finish(err)
+		return err
+	}
+	if err := r.end(); err != nil {
+		r.finish(err)
+		return err
+	}
+	r.finish(nil)
+	r.afterRun()
 	return nil
 }
 
+// Close closes the EventSinks of t which implement io.Closer, which flushes any
+// events they have not sent yet.
+func (t *Tidy) Close() error {
+	var first error
+	for _, s := range t.Sinks {
+		if c, ok := s.(io.Closer); ok {
+			if err := c.Close(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// Journal returns every entry of the journal of the SortDir, oldest first. A
+// SortDir which has never been sorted has an empty journal.
+func (t *Tidy) Journal() ([]JournalEntry, error) {
+	return newJournal(t.Fs, t.SortDir).entries()
+}
+
 // Undo() will move the files sorted in the scaffolding created by a call to Sort()
 // into their parent directory. It will then delete the scaffolding, effectively
 // bringing the directory back to it's previous state before a call to Sort()
+//
+// When the SortDir has a journal, every sort recorded in it that has not been
+// undone yet is reverted, newest first, which also brings back files that were
+// moved to a destination outside of the SortDir. Directories sorted before the
+// journal existed are undone by emptying the scaffolding instead.
 func (t *Tidy) Undo() error {
-	err := t.Sorter.undo(t.Fs)
+	return t.UndoContext(context.Background())
+}
+
+// UndoContext undoes the sorts of the SortDir like Undo, and stops before the
+// next file once ctx is cancelled, returning the error of ctx. The journal only
+// marks the sorts which were undone completely, so a later Undo brings back the
+// files which are still sorted.
+//
+// The index of the SortDir is removed, as entries which a sort skipped may be
+// sortable once the files are back. The SortDir is locked while it is undone,
+// like it is while it is sorted, unless it has no state directory.
+func (t *Tidy) UndoContext(ctx context.Context) error {
+	// a directory without a state directory was sorted before tidy kept any
+	// state. It is not locked, so that undoing it leaves no state behind.
+	if _, err := t.Fs.Stat(filepath.Join(t.SortDir, stateDirName)); err == nil {
+		lock, err := t.lock(ctx)
+		if err != nil {
+			return err
+		}
+		defer t.unlock(lock)
+	}
+	r := t.newRun()
+	r.ctx = ctx
+	if err := removeIndex(t.Fs, t.SortDir); err != nil {
+		return err
+	}
+	if !r.journal.exists() {
+		return t.Sorter.undo(r)
+	}
+
+	runs, err := r.journal.pendingRuns()
 	if err != nil {
 		return err
 	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		if err := r.revert(runs[i]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -119,9 +397,24 @@ func (t *Tidy) Undo() error {
 // a CreateScaffolding() method which creates the directory structure for files to
 // be sorted in, and a Sort() method which sorts a given directory
 type Sorter interface {
-	createScaffolding(fsys afero.Fs) error
-	sort(fsys afero.Fs) error
-	undo(fsys afero.Fs) error
+	createScaffolding(r *run) error
+	sort(r *run) error
+	undo(r *run) error
+
+	// sortEntry sorts a single entry of the directory.
+	sortEntry(r *run, path string, info fs.FileInfo) error
+
+	// classify decides where a single entry of the directory belongs, without
+	// making any changes.
+	classify(r *run, path string, info fs.FileInfo) (*classification, error)
+
+	// inspect fills in the parts of a Status which depend on the scaffolding of
+	// the Sorter, without making any changes.
+	inspect(r *run, s *Status) error
+
+	// clashes finds the files in the way of the scaffolding, and what the clash
+	// policy does about them, without making any changes.
+	clashes(r *run) ([]FolderClash, error)
 }
 
 type FiletypeLookup map[string]*FiletypeSortingFolder
@@ -137,8 +430,6 @@ type FiletypeSorter struct {
 	// values, this allows us to determine where a file should be sorted in constant
 	// time.
 	Lookup FiletypeLookup
-
-	logger zerolog.Logger
 }
 
 // FiletypeSortingFolder represents an individual directory in which files will be sorted
@@ -146,6 +437,16 @@ type FiletypeSorter struct {
 type FiletypeSortingFolder struct {
 	Name string
 
+	// Dest optionally points the folder somewhere other than a directory named
+	// Name in the destination root, for example "~/Pictures/Inbox" for Images.
+	// Files in this folder are moved directly into Dest. A relative Dest is
+	// relative to the SortDir.
+	Dest string
+
+	// Rename overrides the rename rules of the Tidy for files in this folder. A
+	// nil Rename uses the rules of the Tidy, an empty one disables renaming.
+	Rename []RenameRule
+
 	// The Extensions field contains a slice of all file extensions that should be
 	// sorted in this folder.
 	Extensions []string
@@ -195,7 +496,7 @@ func NewFiletypeSorter() *FiletypeSorter {
 		},
 	}
 
-	ftSorter := &FiletypeSorter{Dirs: dirs, logger: logger.Get()}
+	ftSorter := &FiletypeSorter{Dirs: dirs}
 	ftSorter.Lookup = ftSorter.newLookup()
 
 	return ftSorter
@@ -220,6 +521,17 @@ func (fts *FiletypeSorter) newLookup() FiletypeLookup {
 	return lookup
 }
 
+// folder returns the FiletypeSortingFolder with the given name, or nil if the
+// FiletypeSorter has no such folder.
+func (fts *FiletypeSorter) folder(name string) *FiletypeSortingFolder {
+	for _, v := range fts.Dirs {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
 // dirsSlice returns a slice containing the names of all FiletypeSortingFolders in
 // the Dirs slice. The slice returned is sorted lexicographicaly.
 func (fts *FiletypeSorter) dirsSlice() []string {
@@ -235,14 +547,26 @@ func (fts *FiletypeSorter) dirsSlice() []string {
 }
 
 // createScaffolding reads the names of the elements in fts.Dirs and creates
-// directories of the same names in the current working directory.
+// directories of the same names in the destination root, which is the SortDir
+// unless a destination was chosen. Folders with their own Dest
+// are created at that path instead.
 //
 // If there is already a folder with the same name then createScaffolding
 // will refrain from creating that directory. If there is a file with the same
 // name, however, then an error will be returned.
-func (fts *FiletypeSorter) createScaffolding(fsys afero.Fs) error {
+//
+// When the path template does not group files by their category, there are no
+// category folders to create, and the directories are created as files are
+// moved into them instead.
+func (fts *FiletypeSorter) createScaffolding(r *run) error {
+	if !r.template.groupsByCategory() {
+		return nil
+	}
 	for _, v := range fts.Dirs {
-		err := idempotentMkdir(v.Name, fs.ModePerm, fsys)
+		if err := fts.resolveClash(r, v, make(map[*FiletypeSortingFolder]bool)); err != nil {
+			return err
+		}
+		err := r.mkdirAll(r.folderPath(v))
 		if err != nil {
 			return err
 		}
@@ -252,153 +576,233 @@ func (fts *FiletypeSorter) createScaffolding(fsys afero.Fs) error {
 
 // idempotentMkdir will create a directory with the given name if it does not exist
 // if the directory already exists, idempotentMkdir will return without an error.
+// The created return value reports whether the directory was created by this call.
 // This function is safe for concurrent execution.
 //
 // Taken from stackoverflow user @pr-pal: https://stackoverflow.com/a/56600630/18245016
-func idempotentMkdir(name string, perm fs.FileMode, fsys afero.Fs) error {
+func idempotentMkdir(name string, perm fs.FileMode, fsys afero.Fs) (created bool, err error) {
 	// We "do then check" to avoid race conditions, as opposed
 	// to "check then do".
-	err := fsys.Mkdir(name, perm)
+	err = fsys.Mkdir(name, perm)
 	if err == nil {
-		return nil
+		return true, nil
 	}
 	if os.IsExist(err) {
 		// check that the existing path is a directory
 		info, err := fsys.Stat(name)
 		if err != nil {
-			return err
+			return false, err
 		}
 		if !info.IsDir() {
-			// TODO: Need to deal with the case in which there is an existing file, that is not
-			// a directory, but that has the same name as one of the needed directories. Currently
-			// the behavior is to return an error. There should probably be a better solution.
-			return errors.New("path exists but is not a directory")
+			// files in the way of the sorting folders are moved before the
+			// scaffolding is created, see ClashPolicy.
+			return false, fmt.Errorf("%s exists but is not a directory", name)
 		}
-		return nil
+		return false, nil
 	}
-	return err
+	return false, err
 }
 
-func (fts *FiletypeSorter) sort(fsys afero.Fs) error {
-	err := afero.Walk(fsys, ".", func(path string, f fs.FileInfo, err error) error {
+// sort sorts the SortDir in two phases. Every entry is planned first, which
+// decides where it goes, then the planned moves are carried out by up to
+// r.workers workers at a time, see execute.
+//
+// When the context of the run is cancelled, the entries which were not moved
+// yet are counted as remaining in the Result, and the error of the context is
+// returned.
+func (fts *FiletypeSorter) sort(r *run) error {
+	moves, errs, err := fts.plan(r)
+	if err != nil {
+		if r.ctx.Err() != nil {
+			r.result.Remaining += len(moves)
+		}
+		return err
+	}
+
+	r.planned(moves)
+	failures, remaining := r.execute(moves, func(m *plannedMove) error {
+		err := fts.moveTo(r, m)
+		r.advance(m)
 		if err != nil {
-			return err
+			r.emit(Event{Type: EventError, File: m.info.Name(), err: err})
 		}
+		return err
+	})
+	r.result.Remaining = remaining
+	if err := r.ctx.Err(); err != nil {
+		return err
+	}
+	if len(failures) > 0 && r.failFast {
+		return failures[0]
+	}
+	errs = append(errs, failures...)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
 
-		// check if this is a directory, if it is check to see if it is part of the
-		// scaffolding. If it is part of the scaffolding then return, if not - then
-		// move it to the 'Directories' folder.
-		if f.IsDir() {
-			if slices.Contains(fts.dirsSlice(), f.Name()) {
-				return filepath.SkipDir
-			}
-			if path == "." {
-				return nil
-			}
+// sortEntry plans a single entry of the directory and moves it into its folder.
+func (fts *FiletypeSorter) sortEntry(r *run, path string, f fs.FileInfo) error {
+	m, se := fts.planEntry(r, path, f)
+	if se != nil {
+		r.emit(Event{Type: EventError, File: f.Name(), err: se})
+		return se
+	}
+	if m == nil {
+		return nil
+	}
+	if err := fts.moveTo(r, m); err != nil {
+		r.emit(Event{Type: EventError, File: f.Name(), err: err})
+		return err
+	}
+	return nil
+}
 
-			dest := filepath.Join("Directories", f.Name())
-			absDest, _ := filepath.Abs(dest)
+// classify decides which folder the entry at path belongs in. Directories that
+// are part of the scaffolding are left alone, any other directory belongs in the
+// 'Directories' folder. Files are checked against the rules of the run first,
+// and are then looked up by their extension, with files of unknown types
+// belonging in the 'Other' folder.
+func (fts *FiletypeSorter) classify(r *run, path string, f fs.FileInfo) (*classification, error) {
+	c := &classification{isDir: f.IsDir(), explain: r.explain}
+
+	if r.ignore[f.Name()] {
+		c.note("%s is kept by tidy itself or is a sorting destination, it is never sorted", f.Name())
+		return c, nil
+	}
 
-			err := fsys.Rename(f.Name(), dest)
-			if err != nil {
-				return &SortingError{Filename: f.Name(), AbsPath: absDest, Sort: true, Err: err}
-			}
-			fts.logFiletypeSort(f.Name(), absDest, true, true, true)
-			return filepath.SkipDir
+	if f.IsDir() {
+		if r.folders[f.Name()] {
+			c.note("%s is one of the sorting folders, it is left alone", f.Name())
+			return c, nil
 		}
-		ext := getExtension(f.Name())
+		c.note("%s is a directory, directories are sorted as a whole", f.Name())
+		c.folder, c.knownExtension = r.dirsFolder, true
+		return c, nil
+	}
 
-		val, ok := fts.Lookup[ext]
-		if !ok || ext == "" {
-			dest := filepath.Join("Other", f.Name())
-			absDest, _ := filepath.Abs(dest)
-			err := fsys.Rename(f.Name(), dest)
-			if err != nil {
-				return &SortingError{Filename: f.Name(), AbsPath: absDest, Sort: true, Err: err}
-			}
-			fts.logFiletypeSort(f.Name(), absDest, false, false, true)
-			return nil
-		}
-		dest := filepath.Join(val.Name, f.Name())
-		absDest, _ := filepath.Abs(dest)
-		err = fsys.Rename(f.Name(), dest)
-		if err != nil {
-			return &SortingError{Filename: f.Name(), AbsPath: absDest, Sort: true, Err: err}
-		}
-		fts.logFiletypeSort(f.Name(), absDest, false, true, true)
+	rule, err := matchRules(r.rules, r.fs, path, f)
+	if err != nil {
+		return nil, err
+	}
+	if rule != nil {
+		c.note("rule %s matched", rule)
+		c.folder, c.rule, c.knownExtension = rule.folder, rule, true
+		return c, nil
+	}
+	if len(r.rules) > 0 {
+		c.note("none of the %d rules matched", len(r.rules))
+	}
+
+	ext := getExtension(f.Name())
+	if ext == "" {
+		c.note("%s has no extension", f.Name())
+		c.folder = r.otherFolder
+		return c, nil
+	}
+	c.note("found the extension %q", ext)
+
+	val, ok := fts.Lookup[ext]
+	if !ok {
+		c.note("the extension %q is not in the lookup of any folder", ext)
+		c.folder = r.otherFolder
+		return c, nil
+	}
+	c.note("the lookup maps %q to the %s folder", ext, val.Name)
+	c.folder, c.knownExtension = val, true
+	return c, nil
+}
+
+// moveTo carries out the planned move m. If something already exists at its
+// destination, the conflict policy of the run is applied.
+func (fts *FiletypeSorter) moveTo(r *run, m *plannedMove) error {
+	path, c, f := m.path, m.c, m.info
+	resolved, conflict, skip, err := r.resolveConflict(m.dest)
+	if err != nil {
+		return &SortingError{Filename: f.Name(), AbsPath: m.dest, Sort: true, Err: err}
+	}
+	if conflict {
+		r.emit(Event{Type: EventConflict, File: f.Name(), Dest: m.dest, Policy: r.onConflict})
+	}
+	if skip {
+		e := newIndexEntry(f, c.folder.Name)
+		e.Dest = r.rel(m.dest)
+		r.keep(e)
+		r.emit(Event{Type: EventSkip, File: f.Name(), Dest: m.dest, Reason: "the destination already exists"})
 		return nil
-	})
+	}
+	dest := resolved
+
+	vars := hookVars{src: path, dest: dest, name: f.Name(), category: c.folder.Name, sortDir: r.sortDir}
+	if err := r.runHooks(HookBeforeMove, vars); err != nil {
+		// the entry is not indexed, as the hook may let it through next time.
+		r.emit(Event{Type: EventSkip, File: f.Name(), Dest: dest, Reason: err.Error()})
+		return nil
+	}
+
+	err = r.move(path, dest)
 	if err != nil {
-		return err
+		return &SortingError{Filename: f.Name(), AbsPath: dest, Sort: true, Err: err}
+	}
+	var size int64
+	if !c.isDir {
+		size = f.Size()
+	}
+	r.emit(Event{Type: EventMove, File: f.Name(), Dest: dest, IsDir: c.isDir, KnownExtension: c.knownExtension, Category: c.folder.Name, Size: size})
+	if err := r.runHooks(HookOnMove, vars); err != nil {
+		r.emit(Event{Type: EventHookFailed, File: f.Name(), Dest: dest, Category: c.folder.Name, err: err})
 	}
 	return nil
 }
 
-func (fts *FiletypeSorter) undo(fsys afero.Fs) error {
+func (fts *FiletypeSorter) undo(r *run) error {
+	fsys := r.fs
+
 	// dirsSlice is the names of the directories that make up the sorting categories
 	// for the filetype sort.
 	dirsSlice := fts.dirsSlice()
 
-	// dirsInCwd is the names of the directories in the current working directory.
-	dirsInCwd, err := dirsInCwd(fsys)
+	// dirsInSortDir is the names of the directories in the SortDir.
+	dirsInSortDir, err := dirsIn(fsys, r.sortDir)
 	if err != nil {
 		return err
 	}
-	// We want to check if dirsSlice is a subset of dirsInCwd. This would mean that we
+	// We want to check if dirsSlice is a subset of dirsInSortDir. This would mean that we
 	// have all of the sorting directories present, and we want to extract the files out
 	// of them. There may be other directories present, we will just ignore them, as the goal
 	// is to unsort.
-	if sliceIsSubset(dirsSlice, dirsInCwd) {
+	if sliceIsSubset(dirsSlice, dirsInSortDir) {
 
-		for _, v := range dirsInCwd {
+		for _, v := range dirsSlice {
+			dir := filepath.Join(r.sortDir, v)
 
-			err := afero.Walk(fsys, v, func(path string, f fs.FileInfo, err error) error {
-				if err != nil {
+			// the entries of the folder are moved as a whole, directories
+			// included, so a single listing of the folder is enough.
+			entries, err := afero.ReadDir(fsys, dir)
+			if err != nil {
+				return err
+			}
+			for _, f := range entries {
+				if err := r.ctx.Err(); err != nil {
 					return err
 				}
-				// Ignore the root. We don't want to move this
-				if v == path {
-					return nil
-				}
-
-				// absPath, _ := filepath.Abs(v)
-				newPath, err := moveToParentDir(fsys, path)
+				newPath, err := moveToDir(fsys, filepath.Join(dir, f.Name()), r.sortDir)
 				if err != nil {
 					return err
 				}
-				if f.IsDir() {
-					fts.logFiletypeSort(f.Name(), newPath, true, true, false)
-					return filepath.SkipDir
-				}
-				fts.logFiletypeSort(f.Name(), newPath, false, true, false)
-				return nil
-			})
-			if err != nil {
-				return err
+				r.emit(Event{Type: EventRestore, File: f.Name(), Dest: newPath})
 			}
-			err = os.Remove(v)
+			err = fsys.Remove(dir)
 			if err != nil {
 				return err
 			}
-			absPath, _ := filepath.Abs(v)
-			fts.logger.Info().Str("Deleted Directory", absPath).Msg("Deleted directory.")
+			r.emit(Event{Type: EventRmdir, Dest: dir})
 		}
 
 	}
 	return nil
 }
 
-// logFiletypeSort is a helper function for logging the movement of files. The
-// knownExtension argument indicates whether we were able to identify the file
-// based on its extension and thus sort it correctly. The sort arg indicates whether
-// we are performing a sort or unsort operation.
-func (fts *FiletypeSorter) logFiletypeSort(filename, newPath string, isDir, knownExtension, sort bool) {
-	if sort {
-		fts.logger.Info().Str("Moved", filename).Str("New Path", newPath).Bool("Is Dir", isDir).Bool("Known Extension", knownExtension).Msg("Sorted file to new directory.")
-	} else {
-		fts.logger.Info().Str("Moved", filename).Str("New Path", newPath).Bool("Is Dir", isDir).Bool("Known Extension", knownExtension).Msg("Unsorted file to parent directory.")
-	}
-}
-
 type CreatedAtSorter struct {
 }
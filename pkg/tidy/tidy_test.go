@@ -1,8 +1,13 @@
 package tidy
 
 import (
+	"context"
+	"errors"
 	"io/fs"
+	"os"
+	"path/filepath"
 	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -77,12 +82,14 @@ func TestFiletypeSort(t *testing.T) {
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, tc.testID, err)
 			}
+			// fsys is the SortDir, where the files of the test live.
+			fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
 			t.Logf("\t%s\tTest %d:\tShould be able to initialize Tidy struct", success, tc.testID)
 
 			// Setup the initial state of the directory before testing.
 			// Create the initial directories.
 			for _, v := range tc.initialDirsPresent {
-				err := Tidy.Fs.Mkdir(v, 0777)
+				err := fsys.Mkdir(v, 0777)
 				if err != nil {
 					t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of directories in the test filesystem, error: %v", failed, tc.testID, err)
 				}
@@ -91,7 +98,7 @@ func TestFiletypeSort(t *testing.T) {
 
 			// Create the initial files.
 			for _, v := range tc.initialFilesPresent {
-				file, err := Tidy.Fs.Create(v)
+				file, err := fsys.Create(v)
 				if err != nil {
 					t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, tc.testID, err)
 				}
@@ -101,11 +108,11 @@ func TestFiletypeSort(t *testing.T) {
 			t.Logf("\t%s\tTest %d:\tTest successfully setup mock MemMapFS.", success, tc.testID)
 
 			// This is what we're testing
-			if err := Tidy.Sort(); err != nil {
+			if _, err := Tidy.Sort(); err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to call Tidy.Sort() without error: %v", failed, tc.testID, err)
 			}
 
-			got, err := mapOfDirs(t, Tidy.Fs)
+			got, err := mapOfDirs(t, fsys)
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to create map of final directory structure: %v", failed, tc.testID, err)
 			}
@@ -175,12 +182,14 @@ func TestCreateScaffolding(t *testing.T) {
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, tc.testID, err)
 			}
+			// fsys is the SortDir, where the files of the test live.
+			fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
 			t.Logf("\t%s\tTest %d:\tShould be able to initialize Tidy struct", success, tc.testID)
 
 			// Setup the initial state of the directory before testing.
 			// Create the initial directories.
 			for _, v := range tc.initialDirsPresent {
-				err := Tidy.Fs.Mkdir(v, 0777)
+				err := fsys.Mkdir(v, 0777)
 				if err != nil {
 					t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of directories in the test filesystem, error: %v", failed, tc.testID, err)
 				}
@@ -189,7 +198,7 @@ func TestCreateScaffolding(t *testing.T) {
 
 			// Create the initial files.
 			for _, v := range tc.initialFilesPresent {
-				file, err := Tidy.Fs.Create(v)
+				file, err := fsys.Create(v)
 				if err != nil {
 					t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, tc.testID, err)
 				}
@@ -204,7 +213,7 @@ func TestCreateScaffolding(t *testing.T) {
 				t.Fatalf("\t%s\tTest %d:\tShould have called Tidy.CreateScaffolding() without error: %v", failed, tc.testID, err)
 			}
 
-			got, err := sliceOfDirs(t, Tidy.Fs)
+			got, err := sliceOfDirs(t, fsys)
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to create a slice of final directory structure: %v", failed, tc.testID, err)
 			}
@@ -221,7 +230,8 @@ func TestCreateScaffolding(t *testing.T) {
 }
 
 // sliceOfDirContents function walks the current directory and returns a
-// slice containing the name of every directory found.
+// slice containing the name of every directory found. The state directory that
+// tidy keeps its journal in is skipped.
 func sliceOfDirs(t *testing.T, fsys afero.Fs) ([]string, error) {
 	t.Helper()
 	dirsFound := make([]string, 0)
@@ -234,6 +244,9 @@ func sliceOfDirs(t *testing.T, fsys afero.Fs) ([]string, error) {
 			if path == "." {
 				return nil
 			}
+			if path == stateDirName {
+				return filepath.SkipDir
+			}
 			dirsFound = append(dirsFound, info.Name())
 			return nil
 		}
@@ -286,3 +299,374 @@ func mockTidyFlags() *TidyFlags {
 		Verbose: false,
 	}
 }
+
+func TestSortToDestAndUndo(t *testing.T) {
+	t.Log("Given the need to sort files into a destination outside of the sorting folders, and bring them back.")
+
+	testID := 0
+	files := []string{"cat.jpg", "story.txt", "random.xxx"}
+
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	// fsys is the SortDir, where the files of the test live.
+	fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+	t.Logf("\t%s\tTest %d:\tShould be able to initialize Tidy struct", success, testID)
+
+	Tidy.Sorter.(*FiletypeSorter).folder("Images").Dest = filepath.Join("Pictures", "Inbox")
+
+	for _, v := range files {
+		file, err := fsys.Create(v)
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+		file.Close()
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem.", success, testID)
+
+	if _, err := Tidy.Sort(); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to call Tidy.Sort() without error: %v", failed, testID, err)
+	}
+
+	for _, v := range []string{"Pictures/Inbox/cat.jpg", "Documents/story.txt", "Other/random.xxx"} {
+		if _, err := fsys.Stat(v); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould have sorted file to %s: %v", failed, testID, v, err)
+		}
+	}
+	if _, err := fsys.Stat("Images"); err == nil {
+		t.Fatalf("\t%s\tTest %d:\tShould not have created the Images folder when it has its own destination.", failed, testID)
+	}
+	t.Logf("\t%s\tTest %d:\tShould have sorted files to their destinations.", success, testID)
+
+	if err := Tidy.Undo(); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to call Tidy.Undo() without error: %v", failed, testID, err)
+	}
+
+	got, err := afero.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to read the final directory: %v", failed, testID, err)
+	}
+	names := make([]string, 0, len(got))
+	for _, v := range got {
+		if v.Name() != stateDirName {
+			names = append(names, v.Name())
+		}
+	}
+	want := []string{"cat.jpg", "random.xxx", "story.txt"}
+	if !cmp.Equal(names, want) {
+		t.Logf("\t\tTest %d:\texp: %v", testID, want)
+		t.Logf("\t\tTest %d:\tgot: %v", testID, names)
+		t.Fatalf("\t%s\tTest %d:\tShould have moved every file back and removed the scaffolding.", failed, testID)
+	}
+	t.Logf("\t%s\tTest %d:\tShould have moved every file back and removed the scaffolding.", success, testID)
+}
+
+func TestUndoWithSourceTaken(t *testing.T) {
+	t.Log("Given the need to undo a sort without overwriting files created since.")
+
+	testID := 0
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	// fsys is the SortDir, where the files of the test live.
+	fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+	for _, v := range []string{"cat.jpg", "story.txt"} {
+		if err := afero.WriteFile(fsys, v, []byte("sorted"), 0o644); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+	}
+	if _, err := Tidy.Sort(); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to sort: %v", failed, testID, err)
+	}
+	if err := afero.WriteFile(fsys, "cat.jpg", []byte("new"), 0o644); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create a new cat.jpg: %v", failed, testID, err)
+	}
+
+	t.Logf("\tTest %d:\tWhen a new file was created where a sorted file was.", testID)
+	{
+		var conflicts, skips int
+		Tidy.Sinks = []EventSink{sinkFunc(func(e Event) {
+			switch e.Type {
+			case EventConflict:
+				conflicts++
+			case EventSkip:
+				if e.File == "cat.jpg" {
+					skips++
+				}
+			}
+		})}
+		if err := Tidy.Undo(); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to undo: %v", failed, testID, err)
+		}
+		if b, err := afero.ReadFile(fsys, "cat.jpg"); err != nil || string(b) != "new" {
+			t.Fatalf("\t%s\tTest %d:\tShould keep the new cat.jpg, got %q: %v", failed, testID, b, err)
+		}
+		if b, err := afero.ReadFile(fsys, "Images/cat.jpg"); err != nil || string(b) != "sorted" {
+			t.Fatalf("\t%s\tTest %d:\tShould leave the sorted cat.jpg in its folder, got %q: %v", failed, testID, b, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould not overwrite the new file.", success, testID)
+
+		if conflicts != 1 || skips != 1 {
+			t.Fatalf("\t%s\tTest %d:\tShould report the conflict, got %d conflicts and %d skips", failed, testID, conflicts, skips)
+		}
+		t.Logf("\t%s\tTest %d:\tShould report the conflict.", success, testID)
+
+		if _, err := fsys.Stat("story.txt"); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould bring back the other files: %v", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould bring back the other files.", success, testID)
+	}
+}
+
+// renameFailingFs is an afero.Fs which fails to rename the entries with the names
+// in fail.
+type renameFailingFs struct {
+	afero.Fs
+	fail map[string]bool
+}
+
+func (fsys *renameFailingFs) Rename(oldname, newname string) error {
+	if fsys.fail[filepath.Base(oldname)] {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrPermission}
+	}
+	return fsys.Fs.Rename(oldname, newname)
+}
+
+func TestSortBestEffort(t *testing.T) {
+	t.Log("Given the need to sort every file that can be moved, and report the ones that can not.")
+
+	files := []string{"cat.jpg", "locked.jpg", "story.txt", "stuck.mp3"}
+	newTidy := func(t *testing.T, testID int) *Tidy {
+		Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), &renameFailingFs{Fs: afero.NewMemMapFs(), fail: map[string]bool{"locked.jpg": true, "stuck.mp3": true}})
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+		}
+		fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+		for _, v := range files {
+			file, err := fsys.Create(v)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+			}
+			file.Close()
+		}
+		return Tidy
+	}
+
+	testID := 0
+	t.Logf("\tTest %d:\tWhen some of the files can not be moved.", testID)
+	{
+		Tidy := newTidy(t, testID)
+		result, err := Tidy.Sort()
+
+		var errs SortingErrors
+		if !errors.As(err, &errs) {
+			t.Fatalf("\t%s\tTest %d:\tShould return SortingErrors, got %v", failed, testID, err)
+		}
+		if len(errs) != 2 || errs[0].Filename != "locked.jpg" || errs[1].Filename != "stuck.mp3" || !errors.Is(errs[0], fs.ErrPermission) {
+			t.Fatalf("\t%s\tTest %d:\tShould list every file that could not be moved, got %v", failed, testID, errs)
+		}
+		t.Logf("\t%s\tTest %d:\tShould list every file that could not be moved.", success, testID)
+
+		if result.Moved != 2 || result.Errors != 2 {
+			t.Fatalf("\t%s\tTest %d:\tShould move the other files, got %+v", failed, testID, result.Counts)
+		}
+		fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+		for _, v := range []string{"Images/cat.jpg", "Documents/story.txt", "locked.jpg", "stuck.mp3"} {
+			if _, err := fsys.Stat(v); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould find %s: %v", failed, testID, v, err)
+			}
+		}
+		t.Logf("\t%s\tTest %d:\tShould move every other file.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen failing fast.", testID)
+	{
+		Tidy := newTidy(t, testID)
+		Tidy.FailFast = true
+		result, err := Tidy.Sort()
+
+		var se *SortingError
+		if !errors.As(err, &se) || se.Filename != "locked.jpg" {
+			t.Fatalf("\t%s\tTest %d:\tShould return the first SortingError, got %v", failed, testID, err)
+		}
+		if result.Moved != 1 || result.Errors != 1 {
+			t.Fatalf("\t%s\tTest %d:\tShould stop at the first file that can not be moved, got %+v", failed, testID, result.Counts)
+		}
+		t.Logf("\t%s\tTest %d:\tShould stop at the first file that can not be moved.", success, testID)
+	}
+}
+
+// cancelSink cancels a sort once it has moved after entries.
+type cancelSink struct {
+	cancel context.CancelFunc
+	after  int
+	moved  int
+}
+
+func (s *cancelSink) Send(e Event) {
+	if e.Type != EventMove {
+		return
+	}
+	if s.moved++; s.moved == s.after {
+		s.cancel()
+	}
+}
+
+func TestSortContext(t *testing.T) {
+	t.Log("Given the need to stop a sort or an undo part way, and finish it later.")
+
+	testID := 0
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	// fsys is the SortDir, where the files of the test live.
+	fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+	files := []string{"a.jpg", "b.txt", "c.mp3", "d.pdf", "e.zip"}
+	for _, v := range files {
+		if err := afero.WriteFile(fsys, v, nil, 0o644); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+	}
+
+	t.Logf("\tTest %d:\tWhen the sort is cancelled after the first move.", testID)
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		Tidy.Sinks = []EventSink{&cancelSink{cancel: cancel, after: 1}}
+		result, err := Tidy.SortContext(ctx)
+		Tidy.Sinks = nil
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("\t%s\tTest %d:\tShould return context.Canceled, got %v", failed, testID, err)
+		}
+		if !result.Cancelled || result.Moved != 1 || result.Remaining != len(files)-1 {
+			t.Fatalf("\t%s\tTest %d:\tShould report 1 move and %d remaining entries, got %+v", failed, testID, len(files)-1, result)
+		}
+		t.Logf("\t%s\tTest %d:\tShould stop after the move which already started.", success, testID)
+
+		for _, v := range []string{"Images/a.jpg", "b.txt", "c.mp3", "d.pdf", "e.zip"} {
+			if _, err := fsys.Stat(v); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould find %s: %v", failed, testID, v, err)
+			}
+		}
+		t.Logf("\t%s\tTest %d:\tShould leave the other files where they are.", success, testID)
+
+		s, err := Tidy.Status()
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to get the status: %v", failed, testID, err)
+		}
+		if len(s.Pending) != 1 || s.Pending[0].State != RunUndoable || s.Pending[0].Moves != 1 {
+			t.Fatalf("\t%s\tTest %d:\tShould journal the sort as finished with 1 move, got %+v", failed, testID, s.Pending)
+		}
+		t.Logf("\t%s\tTest %d:\tShould leave a journal which can be undone.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen the undo is cancelled before it starts.", testID)
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := Tidy.UndoContext(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("\t%s\tTest %d:\tShould return context.Canceled, got %v", failed, testID, err)
+		}
+		if _, err := fsys.Stat("Images/a.jpg"); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould not have moved anything: %v", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould not move anything.", success, testID)
+
+		if err := Tidy.Undo(); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to undo: %v", failed, testID, err)
+		}
+		for _, v := range files {
+			if _, err := fsys.Stat(v); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould have brought back %s: %v", failed, testID, v, err)
+			}
+		}
+		t.Logf("\t%s\tTest %d:\tShould undo the rest on the next undo.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen the sort is cancelled before the entries are planned.", testID)
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		Tidy.Sinks = []EventSink{sinkFunc(func(e Event) {
+			if e.Type == EventRunStarted {
+				cancel()
+			}
+		})}
+		result, err := Tidy.SortContext(ctx)
+		Tidy.Sinks = nil
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("\t%s\tTest %d:\tShould return context.Canceled, got %v", failed, testID, err)
+		}
+		if !result.Cancelled || result.Moved != 0 || result.Remaining != len(files) {
+			t.Fatalf("\t%s\tTest %d:\tShould report %d remaining entries, without the sorting folders, got %+v", failed, testID, len(files), result)
+		}
+		t.Logf("\t%s\tTest %d:\tShould count the entries it had not looked at yet as remaining.", success, testID)
+	}
+}
+
+func TestSortConcurrently(t *testing.T) {
+	t.Log("Given the need to sort several directories at the same time, in one process.")
+
+	testID := 0
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to get the working directory: %v", failed, testID, err)
+	}
+	fsys := afero.NewMemMapFs()
+	dirs := []string{"/home/downloads", "/home/desktop"}
+	tidies := make([]*Tidy, 0, len(dirs))
+	for _, dir := range dirs {
+		for _, v := range []string{"cat.jpg", "story.txt"} {
+			if err := afero.WriteFile(fsys, filepath.Join(dir, v), nil, 0o644); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+			}
+		}
+		Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), fsys)
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+		}
+		if err := Tidy.ChangeSortDir(dir); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to change the sort directory: %v", failed, testID, err)
+		}
+		tidies = append(tidies, Tidy)
+	}
+
+	t.Logf("\tTest %d:\tWhen sorting two directories at once.", testID)
+	{
+		var wg sync.WaitGroup
+		errs := make([]error, len(tidies))
+		for i := range tidies {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = tidies[i].Sort()
+			}(i)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to sort: %v", failed, testID, err)
+			}
+		}
+
+		for _, dir := range dirs {
+			for _, v := range []string{"Images/cat.jpg", "Documents/story.txt"} {
+				if _, err := fsys.Stat(filepath.Join(dir, v)); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould have sorted %s in %s: %v", failed, testID, v, dir, err)
+				}
+			}
+		}
+		t.Logf("\t%s\tTest %d:\tShould sort each directory into itself.", success, testID)
+
+		if got, err := os.Getwd(); err != nil || got != wd {
+			t.Fatalf("\t%s\tTest %d:\tShould leave the working directory at %s, got %s: %v", failed, testID, wd, got, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould leave the working directory alone.", success, testID)
+	}
+}
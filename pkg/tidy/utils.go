@@ -1,10 +1,8 @@
 package tidy
 
 import (
-	"io/fs"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 
 	"github.com/spf13/afero"
@@ -20,49 +18,34 @@ func getExtension(filename string) string {
 	return strings.TrimPrefix(ext, ".")
 }
 
-// dirsInCwd walks the current directory and returns a slice containing the name of
-// every directory found. The returned slice will be lexicographically sorted.
-// TODO: I can refactor this and many other pieces of code in this package using
-// the os.ReadDir function, which returns all directory entries sorted by filename
-// This would greatly simplify the code - the Walk function is needlessly complex
-// for its simple purpose here.
-func dirsInCwd(fsys afero.Fs) ([]string, error) {
+// dirsIn lists the directory dir and returns a slice containing the name of
+// every directory found in it. The returned slice will be lexicographically
+// sorted, as afero.ReadDir sorts the entries by name.
+func dirsIn(fsys afero.Fs, dir string) ([]string, error) {
+	entries, err := afero.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
 	dirsFound := make([]string, 0)
-
-	err := afero.Walk(fsys, ".", func(path string, info fs.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+	for _, info := range entries {
 		if info.IsDir() {
-			if path == "." {
-				return nil
-			}
 			dirsFound = append(dirsFound, info.Name())
-			return filepath.SkipDir
 		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
 	}
-	sort.Slice(dirsFound, func(i, j int) bool {
-		return dirsFound[i] < dirsFound[j]
-	})
 	return dirsFound, nil
 }
 
-// moveToParentDir will take the file located at the path arguement, and move it
-// into the parent directory. An error will be returned if the renaming was unsuccesful.
-// If the renaming was succesfull the newPath return value will be an absolute path to
-// the file.
+// moveToDir will take the file located at the path arguement, and move it into
+// the directory dir. An error will be returned if the renaming was unsuccesful.
+// If the renaming was succesfull the newPath return value will be the path of
+// the file in dir.
 //
 // For example: if you pass in a file located at "/Users/duexcoast/Downloads/test/myfile.txt"
-// it will be moved to "/Users/duexcoast/Downloads/myfile.txt"
-func moveToParentDir(fsys afero.Fs, path string) (newPath string, err error) {
+// and the directory "/Users/duexcoast/Downloads", it will be moved to
+// "/Users/duexcoast/Downloads/myfile.txt"
+func moveToDir(fsys afero.Fs, path, dir string) (newPath string, err error) {
 	fileName := filepath.Base(path)
-	wd, _ := os.Getwd()
-
-	dest := filepath.Join(wd, fileName)
+	dest := filepath.Join(dir, fileName)
 
 	err = fsys.Rename(path, dest)
 	if err != nil {
@@ -91,3 +74,26 @@ func sliceIsSubset(s1, s2 []string) bool {
 
 	return true
 }
+
+// absPath returns the absolute representation of path. If the absolute path can
+// not be determined, path is returned unchanged.
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
+
+// expandHome replaces a leading "~" in path with the home directory of the
+// current user.
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
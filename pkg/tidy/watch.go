@@ -0,0 +1,196 @@
+package tidy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultSettle is how long an entry has to stay unchanged before a Watcher
+// sorts it.
+const DefaultSettle = 2 * time.Second
+
+// partialSuffixes are the extensions browsers and download tools give to files
+// which are still being written. They are renamed once complete, so the Watcher
+// never sorts them.
+var partialSuffixes = []string{".crdownload", ".part", ".partial", ".download", ".tmp"}
+
+// Watcher sorts new entries of the SortDir as they appear, once they have
+// settled. It is created with Tidy.NewWatcher, and runs until the context
+// passed to Run is cancelled.
+type Watcher struct {
+	tidy *Tidy
+
+	// Settle is how long an entry has to go without changes before it is
+	// sorted, so that files which are still being written are left alone.
+	Settle time.Duration
+
+	// Lock, when set, is held while the Watcher sorts, so that it does not sort
+	// at the same time as other sorts of the same directory.
+	Lock sync.Locker
+
+	paused atomic.Bool
+
+	// pending holds the entries that changed recently, along with their last
+	// seen size and the time they last changed.
+	pending map[string]*pendingEntry
+}
+
+type pendingEntry struct {
+	size    int64
+	changed time.Time
+}
+
+// NewWatcher returns a Watcher for the SortDir of t, which waits for entries to
+// settle for DefaultSettle.
+func (t *Tidy) NewWatcher() *Watcher {
+	return &Watcher{tidy: t, Settle: DefaultSettle, pending: make(map[string]*pendingEntry)}
+}
+
+// Run watches the SortDir until ctx is cancelled, sorting every entry that is
+// created or changed once it has settled. Errors sorting individual entries are
+// logged, and do not stop the Watcher.
+//
+// Only the top level of the SortDir is watched, so files moving into the
+// scaffolding are never seen again. The scaffolding directories and the
+// destinations themselves are skipped like they are by Sort.
+func (w *Watcher) Run(ctx context.Context) error {
+	if err := w.tidy.checkProtected(w.tidy.SortDir); err != nil {
+		return err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(w.tidy.SortDir); err != nil {
+		return err
+	}
+	w.tidy.logger.Info().Str("Directory", w.tidy.SortDir).Dur("Settle", w.Settle).Msg("Watching directory.")
+
+	tick := w.Settle / 4
+	if tick <= 0 {
+		tick = time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			w.observe(event)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			w.tidy.logger.Error().Err(err).Msg("Error watching directory.")
+		case now := <-ticker.C:
+			w.sortSettled(now)
+		}
+	}
+}
+
+// Pause stops the Watcher from sorting until Resume is called. Entries which
+// change in the meantime are remembered, and sorted once the Watcher resumes.
+func (w *Watcher) Pause() {
+	w.paused.Store(true)
+}
+
+// Resume undoes Pause.
+func (w *Watcher) Resume() {
+	w.paused.Store(false)
+}
+
+// Paused reports whether the Watcher is paused.
+func (w *Watcher) Paused() bool {
+	return w.paused.Load()
+}
+
+// observe records a filesystem event for the entry it refers to.
+func (w *Watcher) observe(event fsnotify.Event) {
+	name := filepath.Base(event.Name)
+	if filepath.Dir(event.Name) != filepath.Clean(w.tidy.SortDir) || isPartial(name) {
+		return
+	}
+	if event.Has(fsnotify.Remove) {
+		delete(w.pending, name)
+		return
+	}
+
+	// a rename event refers to the old name of the entry, which is gone. The new
+	// name gets its own create event.
+	if event.Has(fsnotify.Rename) {
+		delete(w.pending, name)
+		return
+	}
+	p, ok := w.pending[name]
+	if !ok {
+		p = &pendingEntry{size: -1}
+		w.pending[name] = p
+	}
+	p.changed = time.Now()
+}
+
+// sortSettled sorts every pending entry which has not changed for at least the
+// settle duration. Entries whose size changed since they were last seen are
+// given more time.
+func (w *Watcher) sortSettled(now time.Time) {
+	if len(w.pending) == 0 || w.Paused() {
+		return
+	}
+	if w.Lock != nil {
+		w.Lock.Lock()
+		defer w.Lock.Unlock()
+	}
+
+	for name, p := range w.pending {
+		if now.Sub(p.changed) < w.Settle {
+			continue
+		}
+		info, err := w.tidy.Fs.Stat(filepath.Join(w.tidy.SortDir, name))
+		if err != nil {
+			delete(w.pending, name)
+			continue
+		}
+		if !info.IsDir() && info.Size() != p.size {
+			p.size, p.changed = info.Size(), now
+			continue
+		}
+		delete(w.pending, name)
+
+		err = w.tidy.SortFile(name)
+		var le *LockedError
+		if errors.As(err, &le) {
+			// another run is sorting the directory, try again once it settles.
+			p.changed = now
+			w.pending[name] = p
+			w.tidy.logger.Debug().Err(err).Str("File", name).Msg("Directory is locked, sorting the file later.")
+			continue
+		}
+		if err != nil && !os.IsNotExist(err) {
+			w.tidy.logger.Error().Err(err).Str("File", name).Msg("Could not sort file.")
+		}
+	}
+}
+
+func isPartial(name string) bool {
+	for _, suffix := range partialSuffixes {
+		if strings.HasSuffix(strings.ToLower(name), suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package tidy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestWatcher(t *testing.T) {
+	t.Log("Given the need to sort new files as they appear in a watched directory.")
+
+	testID := 0
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewOsFs())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create a temporary directory: %v", failed, testID, err)
+	}
+	if err := Tidy.ChangeSortDir(dir); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to change the sort directory: %v", failed, testID, err)
+	}
+
+	w := Tidy.NewWatcher()
+	w.Settle = 50 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	// give the watcher a moment to start watching before creating files.
+	time.Sleep(100 * time.Millisecond)
+	for _, v := range []string{"story.txt", "movie.mp4.crdownload"} {
+		if err := os.WriteFile(filepath.Join(dir, v), []byte("tidy"), 0o644); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to create %s: %v", failed, testID, v, err)
+		}
+	}
+
+	sorted := filepath.Join(dir, "Documents", "story.txt")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(sorted); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("\t%s\tTest %d:\tShould have sorted story.txt into the Documents folder.", failed, testID)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Logf("\t%s\tTest %d:\tShould have sorted story.txt into the Documents folder.", success, testID)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould have stopped watching without error: %v", failed, testID, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "movie.mp4.crdownload")); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould have left the partial download alone: %v", failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould have left the partial download alone.", success, testID)
+}
@@ -0,0 +1,219 @@
+package tidy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/duexcoast/tidy-up/pkg/logger"
+	"github.com/rs/zerolog"
+)
+
+// Defaults of a WebhookSink, for the settings left empty in its WebhookConfig.
+const (
+	DefaultWebhookBatchSize     = 20
+	DefaultWebhookFlushInterval = time.Second
+	DefaultWebhookRetries       = 3
+	DefaultWebhookRetryBackoff  = 500 * time.Millisecond
+)
+
+// webhookQueueSize is the most events a WebhookSink holds while it is sending,
+// events sent while the queue is full are dropped.
+const webhookQueueSize = 10_000
+
+// WebhookSink is an EventSink which POSTs events to a URL as JSON, in the form
+// {"events": [...]}. Events are collected in the background and sent in batches,
+// once a batch is full or the flush interval has passed. A request which fails
+// with a network error or a 5xx or 429 status is retried, waiting twice as long
+// before every attempt. A batch which still fails is dropped and logged.
+//
+// Sending never blocks the sort. Events are queued while a batch is being sent,
+// and dropped once the queue is full, such as when the webhook can not be
+// reached, which is logged with the next batch.
+//
+// A WebhookSink must be closed once done, which sends the events still waiting.
+type WebhookSink struct {
+	url           string
+	headers       map[string]string
+	batchSize     int
+	flushInterval time.Duration
+	retries       int
+	retryBackoff  time.Duration
+	client        *http.Client
+
+	// mu guards closed, so that events sent after Close are dropped instead of
+	// being sent on the closed channel, and dropped, the number of events
+	// dropped because the queue was full.
+	mu      sync.Mutex
+	closed  bool
+	dropped int
+	events  chan Event
+	done    chan struct{}
+	logger  zerolog.Logger
+}
+
+// webhookBatch is the body of every request of a WebhookSink.
+type webhookBatch struct {
+	Events []Event `json:"events"`
+}
+
+// NewWebhookSink starts a WebhookSink for the webhook described by wc.
+func NewWebhookSink(wc WebhookConfig) (*WebhookSink, error) {
+	if wc.URL == "" {
+		return nil, errors.New("webhook: no url")
+	}
+	ws := &WebhookSink{
+		url:           wc.URL,
+		headers:       wc.Headers,
+		batchSize:     wc.BatchSize,
+		flushInterval: wc.FlushInterval,
+		retries:       wc.Retries,
+		retryBackoff:  wc.RetryBackoff,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		done:          make(chan struct{}),
+		logger:        logger.Get(),
+	}
+	if ws.batchSize <= 0 {
+		ws.batchSize = DefaultWebhookBatchSize
+	}
+	if ws.flushInterval <= 0 {
+		ws.flushInterval = DefaultWebhookFlushInterval
+	}
+	if ws.retries < 0 {
+		ws.retries = 0
+	} else if ws.retries == 0 {
+		ws.retries = DefaultWebhookRetries
+	}
+	if ws.retryBackoff <= 0 {
+		ws.retryBackoff = DefaultWebhookRetryBackoff
+	}
+	ws.events = make(chan Event, webhookQueueSize)
+
+	go ws.loop()
+	return ws, nil
+}
+
+// Send queues e to be sent with the next batch. It never blocks, events sent
+// while the queue is full or after Close are dropped.
+func (ws *WebhookSink) Send(e Event) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		ws.logger.Warn().Str("URL", ws.url).Str("Event", string(e.Type)).Msg("Dropped an event sent to a closed webhook.")
+		return
+	}
+	select {
+	case ws.events <- e:
+	default:
+		ws.dropped++
+	}
+}
+
+// Close sends the events which are still queued, and stops the WebhookSink.
+// Closing it again does nothing.
+func (ws *WebhookSink) Close() error {
+	ws.mu.Lock()
+	if !ws.closed {
+		ws.closed = true
+		close(ws.events)
+	}
+	ws.mu.Unlock()
+	<-ws.done
+	return nil
+}
+
+func (ws *WebhookSink) loop() {
+	defer close(ws.done)
+
+	ticker := time.NewTicker(ws.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, ws.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := ws.post(batch); err != nil {
+			ws.logger.Error().Err(err).Str("URL", ws.url).Int("Events", len(batch)).Msg("Could not send events to webhook, dropping them.")
+		}
+		batch = make([]Event, 0, ws.batchSize)
+
+		ws.mu.Lock()
+		dropped := ws.dropped
+		ws.dropped = 0
+		ws.mu.Unlock()
+		if dropped > 0 {
+			ws.logger.Warn().Str("URL", ws.url).Int("Events", dropped).Msg("Dropped events, the webhook could not keep up.")
+		}
+	}
+
+	for {
+		select {
+		case e, ok := <-ws.events:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= ws.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// post sends a batch of events, retrying failures which may be temporary.
+func (ws *WebhookSink) post(events []Event) error {
+	body, err := json.Marshal(webhookBatch{Events: events})
+	if err != nil {
+		return err
+	}
+
+	backoff := ws.retryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := ws.try(body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= ws.retries {
+			return err
+		}
+		ws.logger.Warn().Err(err).Str("URL", ws.url).Int("Attempt", attempt+1).Msg("Webhook request failed, retrying.")
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// try makes a single request, and reports whether it is worth retrying if it
+// failed.
+func (ws *WebhookSink) try(body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, ws.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range ws.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := ws.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("webhook responded with %s", resp.Status)
+	default:
+		return false, fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+}
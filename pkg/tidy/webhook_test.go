@@ -0,0 +1,139 @@
+package tidy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestWebhookSink(t *testing.T) {
+	t.Log("Given the need to send sort activity to a webhook.")
+
+	var (
+		mu       sync.Mutex
+		requests int
+		events   []Event
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		// the first request fails, to make sure it is retried.
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var batch webhookBatch
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		events = append(events, batch.Events...)
+	}))
+	defer srv.Close()
+
+	testID := 0
+	files := []string{"cat.jpg", "story.txt", "random.xxx", "config.lua"}
+
+	Tidy, err := NewTidy(NewFiletypeSorter(), mockTidyFlags(), afero.NewMemMapFs())
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to initialize Tidy struct, error: %v", failed, testID, err)
+	}
+	// fsys is the SortDir, where the files of the test live.
+	fsys := afero.NewBasePathFs(Tidy.Fs, Tidy.SortDir)
+	for _, v := range files {
+		file, err := fsys.Create(v)
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to setup starting state of files in the test filesystem: %v", failed, testID, err)
+		}
+		file.Close()
+	}
+	sink, err := NewWebhookSink(WebhookConfig{URL: srv.URL, BatchSize: 3, FlushInterval: time.Hour, RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create the webhook sink: %v", failed, testID, err)
+	}
+	Tidy.Sinks = []EventSink{sink}
+
+	t.Logf("\tTest %d:\tWhen sorting with a webhook configured.", testID)
+	{
+		if _, err := Tidy.Sort(); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to sort: %v", failed, testID, err)
+		}
+		if err := Tidy.Close(); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to close the sinks: %v", failed, testID, err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		moves := 0
+		for _, e := range events {
+			if e.Type == EventMove {
+				moves++
+				if e.File == "" || e.Dest == "" {
+					t.Fatalf("\t%s\tTest %d:\tShould describe the move, got %+v", failed, testID, e)
+				}
+			}
+		}
+		if moves != len(files) {
+			t.Fatalf("\t%s\tTest %d:\tShould have sent %d move events, got %d: %+v", failed, testID, len(files), moves, events)
+		}
+		if events[0].Type != EventRunStarted || events[len(events)-1].Type != EventRunFinished {
+			t.Fatalf("\t%s\tTest %d:\tShould have sent the start and the finish of the run, got %+v", failed, testID, events)
+		}
+		if events[len(events)-1].Moved != moves {
+			t.Fatalf("\t%s\tTest %d:\tShould report %d moved files when the run finishes, got %d", failed, testID, moves, events[len(events)-1].Moved)
+		}
+		t.Logf("\t%s\tTest %d:\tShould send an event for the start and finish of the run and for every move.", success, testID)
+
+		// every batch holds 3 events, and one request failed.
+		if want := (len(events)+2)/3 + 1; requests != want {
+			t.Fatalf("\t%s\tTest %d:\tShould have sent the events in %d requests, got %d", failed, testID, want, requests)
+		}
+		t.Logf("\t%s\tTest %d:\tShould send events in batches, and retry failed requests.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen sending to the webhook once it is closed.", testID)
+	{
+		sink.Send(Event{Type: EventMove})
+		if err := sink.Close(); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to close the sink again: %v", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould drop the event instead of panicking.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen the webhook does not answer.", testID)
+	{
+		release := make(chan struct{})
+		stuck := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer stuck.Close()
+		sink, err := NewWebhookSink(WebhookConfig{URL: stuck.URL, BatchSize: 100, Retries: -1})
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to create the webhook sink: %v", failed, testID, err)
+		}
+
+		sent := make(chan struct{})
+		go func() {
+			for i := 0; i < webhookQueueSize*2; i++ {
+				sink.Send(Event{Type: EventMove})
+			}
+			close(sent)
+		}()
+		select {
+		case <-sent:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("\t%s\tTest %d:\tShould not block while the webhook does not answer", failed, testID)
+		}
+		close(release)
+		sink.Close()
+		t.Logf("\t%s\tTest %d:\tShould drop the events it can not queue instead of blocking.", success, testID)
+	}
+}